@@ -0,0 +1,7 @@
+module mcp-secrets
+
+go 1.21
+
+require securitymcp-hub/pkg v0.0.0
+
+replace securitymcp-hub/pkg => ../../pkg
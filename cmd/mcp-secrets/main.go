@@ -0,0 +1,106 @@
+// mcp-secrets 生成和查看各 MCP 服务使用的加密凭证文件。
+//
+//	mcp-secrets encrypt -passphrase-file pass.txt -in secrets.env -out secrets.enc
+//	mcp-secrets list -passphrase-file pass.txt secrets.enc
+//
+// secrets.env 每行为 NAME=value，例如 FOFA_KEY=xxx；-in 省略时从标准输入读取。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+
+	"securitymcp-hub/pkg/secrets"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "encrypt":
+		encrypt(os.Args[2:])
+	case "list":
+		list(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法:")
+	fmt.Fprintln(os.Stderr, "  mcp-secrets encrypt -passphrase-file 口令文件 [-in 明文凭证] -out 加密凭证文件")
+	fmt.Fprintln(os.Stderr, "  mcp-secrets list -passphrase-file 口令文件 加密凭证文件")
+	os.Exit(2)
+}
+
+func encrypt(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	passphraseFile := fs.String("passphrase-file", "", "口令文件")
+	in := fs.String("in", "", "明文凭证，每行为 NAME=value；为空时从标准输入读取")
+	out := fs.String("out", "", "输出的加密凭证文件")
+	iterations := fs.Int("iterations", secrets.DefaultIterations, "PBKDF2 迭代次数")
+	fs.Parse(args)
+	if *passphraseFile == "" || *out == "" {
+		usage()
+	}
+
+	passphrase, err := secrets.ReadPassphrase(*passphraseFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var input io.Reader = os.Stdin
+	if *in != "" {
+		file, err := os.Open(*in)
+		if err != nil {
+			log.Fatalf("读取明文凭证失败: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+	values, err := secrets.ParseEnv(input)
+	if err != nil {
+		log.Fatalf("解析明文凭证失败: %v", err)
+	}
+	if len(values) == 0 {
+		log.Fatal("没有任何凭证")
+	}
+
+	data, err := secrets.Encrypt(values, passphrase, *iterations)
+	if err != nil {
+		log.Fatalf("加密失败: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0o600); err != nil {
+		log.Fatalf("写入加密凭证文件失败: %v", err)
+	}
+	fmt.Printf("已写入 %d 个凭证到 %s\n", len(values), *out)
+}
+
+func list(args []string) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	passphraseFile := fs.String("passphrase-file", "", "口令文件")
+	fs.Parse(args)
+	if *passphraseFile == "" || fs.NArg() != 1 {
+		usage()
+	}
+
+	passphrase, err := secrets.ReadPassphrase(*passphraseFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	file, err := secrets.OpenFile(fs.Arg(0), passphrase)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// 只列出名称，不输出凭证的值
+	names := file.Names()
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Println(name)
+	}
+}
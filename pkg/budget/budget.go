@@ -0,0 +1,361 @@
+// Package budget 限制单个进程和每天的 API 用量，避免智能体循环查询耗尽账号额度。
+//
+// 用量按三项统计：API 请求次数、取回的结果数和消耗的积分。请求前按预计用量预留，
+// 超出任一上限时直接拒绝；请求完成后按实际用量结算。
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// API 用量
+type Usage struct {
+	Calls   int `json:"calls"`   // API 请求次数
+	Results int `json:"results"` // 取回的结果数
+	Points  int `json:"points"`  // 消耗的积分
+}
+
+func (u Usage) add(v Usage) Usage {
+	return Usage{Calls: u.Calls + v.Calls, Results: u.Results + v.Results, Points: u.Points + v.Points}
+}
+
+func (u Usage) sub(v Usage) Usage {
+	return Usage{Calls: u.Calls - v.Calls, Results: u.Results - v.Results, Points: u.Points - v.Points}
+}
+
+// 用量上限，各项为 0 表示不限制
+type Limits Usage
+
+// 是否没有任何限制
+func (l Limits) Unlimited() bool {
+	return l == Limits{}
+}
+
+// 校验上限不为负数，name 为配置项前缀，例如 fofa.budget.daily
+func (l Limits) Validate(name string) error {
+	for _, item := range l.items(Usage{}) {
+		if item.limit < 0 {
+			return fmt.Errorf("配置项 %s_%s 不能小于 0，当前为 %d", name, item.key, item.limit)
+		}
+	}
+	return nil
+}
+
+type limitItem struct {
+	key   string
+	name  string
+	limit int
+	used  int
+}
+
+func (l Limits) items(used Usage) []limitItem {
+	return []limitItem{
+		{"calls", "API 请求次数", l.Calls, used.Calls},
+		{"results", "结果数", l.Results, used.Results},
+		{"points", "积分", l.Points, used.Points},
+	}
+}
+
+// 预算用尽或本次请求的预计用量超出剩余预算
+var ErrExhausted = errors.New("预算已用尽")
+
+// 超出预算的详细信息
+type ExceededError struct {
+	Scope     string    // 本进程或今日
+	Item      string    // 超出的用量项
+	Limit     int       // 上限
+	Used      int       // 已用
+	Requested int       // 本次请求的预计用量
+	ResetAt   time.Time // 每日预算的重置时间，进程预算为零值
+}
+
+func (e *ExceededError) Error() string {
+	var msg string
+	if e.Used >= e.Limit {
+		msg = fmt.Sprintf("%s的%s预算已用尽（已用 %d，上限 %d）", e.Scope, e.Item, e.Used, e.Limit)
+	} else {
+		msg = fmt.Sprintf("本次请求预计使用%s %d，超出%s剩余的 %d（上限 %d），请减少每页数量",
+			e.Item, e.Requested, e.Scope, e.Limit-e.Used, e.Limit)
+	}
+	if !e.ResetAt.IsZero() {
+		msg += fmt.Sprintf("，预算将于 %s 重置", e.ResetAt.Format("2006-01-02 15:04"))
+	}
+	return msg
+}
+
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExhausted
+}
+
+// 用量预算，nil 表示不限制
+type Guard struct {
+	process Limits
+	daily   Limits
+	file    string // 每日用量的保存文件，为空时不保存
+	now     func() time.Time
+
+	mu          sync.Mutex
+	processUsed Usage
+	day         string // 每日用量对应的日期（本地时间）
+	dailyUsed   Usage
+}
+
+// 创建预算，process 和 daily 都不限制时返回 nil
+func New(process, daily Limits) *Guard {
+	if process.Unlimited() && daily.Unlimited() {
+		return nil
+	}
+	return &Guard{process: process, daily: daily, now: time.Now}
+}
+
+// 是否限制了积分用量，未限制时请求前无需估算积分消耗
+func (g *Guard) LimitsPoints() bool {
+	return g != nil && (g.process.Points > 0 || g.daily.Points > 0)
+}
+
+// 按配置创建预算。file 不为空时每日用量保存在该文件中，重启后继续计数；
+// 同一文件不应被同时运行的多个进程使用
+func Open(process, daily Limits, file string) (*Guard, error) {
+	g := New(process, daily)
+	if g == nil || file == "" {
+		return g, nil
+	}
+	g.file = file
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return nil, fmt.Errorf("创建预算文件目录失败: %w", err)
+	}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取预算文件失败: %w", err)
+	}
+	var state savedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("解析预算文件 %s 失败: %w", file, err)
+	}
+	if state.Day == g.today() {
+		g.day, g.dailyUsed = state.Day, state.Used
+	}
+	return g, nil
+}
+
+// 预算文件内容
+type savedState struct {
+	Day  string `json:"day"`
+	Used Usage  `json:"used"`
+}
+
+func (g *Guard) today() string {
+	return g.now().Format("2006-01-02")
+}
+
+// 下一次重置每日预算的时间（本地时间零点）
+func (g *Guard) resetAt() time.Time {
+	now := g.now()
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+}
+
+// 跨天时清零每日用量，调用方需持有锁
+func (g *Guard) rollover() {
+	if today := g.today(); g.day != today {
+		g.day = today
+		g.dailyUsed = Usage{}
+	}
+}
+
+// 按预计用量预留预算，超出任一上限时返回 ErrExhausted 类型的错误。
+// 请求完成后应调用返回值的 Settle 按实际用量结算
+func (g *Guard) Reserve(estimate Usage) (*Reservation, error) {
+	if g == nil {
+		return nil, nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollover()
+
+	if err := check("本进程", g.process, g.processUsed, estimate, time.Time{}); err != nil {
+		return nil, err
+	}
+	if err := check("今日", g.daily, g.dailyUsed, estimate, g.resetAt()); err != nil {
+		return nil, err
+	}
+	g.processUsed = g.processUsed.add(estimate)
+	g.dailyUsed = g.dailyUsed.add(estimate)
+	return &Reservation{guard: g, estimate: estimate, day: g.day}, nil
+}
+
+func check(scope string, limits Limits, used, estimate Usage, resetAt time.Time) error {
+	requested := limits.items(estimate)
+	for i, item := range limits.items(used) {
+		if item.limit == 0 {
+			continue
+		}
+		// 已用尽时拒绝所有请求；未用尽时拒绝预计用量超出剩余预算的请求
+		if item.used >= item.limit || item.used+requested[i].used > item.limit {
+			return &ExceededError{
+				Scope:     scope,
+				Item:      item.name,
+				Limit:     item.limit,
+				Used:      item.used,
+				Requested: requested[i].used,
+				ResetAt:   resetAt,
+			}
+		}
+	}
+	return nil
+}
+
+// 已预留的预算
+type Reservation struct {
+	guard    *Guard
+	estimate Usage
+	day      string
+	settled  bool
+}
+
+// 按实际用量结算，多次调用只有第一次生效
+func (r *Reservation) Settle(actual Usage) {
+	if r == nil {
+		return
+	}
+	g := r.guard
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if r.settled {
+		return
+	}
+	r.settled = true
+
+	g.processUsed = g.processUsed.sub(r.estimate).add(actual)
+	g.rollover()
+	if g.day == r.day {
+		g.dailyUsed = g.dailyUsed.sub(r.estimate).add(actual)
+	} else {
+		// 预留后已跨天，预留的用量随前一天一起清零
+		g.dailyUsed = g.dailyUsed.add(actual)
+	}
+	g.save()
+}
+
+// 保存每日用量，调用方需持有锁。写入失败只影响重启后的计数，不影响本进程
+func (g *Guard) save() {
+	if g.file == "" {
+		return
+	}
+	data, err := json.Marshal(savedState{Day: g.day, Used: g.dailyUsed})
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(g.file), filepath.Base(g.file)+".*.tmp")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), g.file); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// 工具结果中的预算信息
+type Report struct {
+	Exhausted bool    `json:"exhausted"`         // 是否有任一项预算已用尽
+	Process   *Window `json:"process,omitempty"` // 本进程预算，未限制时省略
+	Daily     *Window `json:"daily,omitempty"`   // 今日预算，未限制时省略
+}
+
+// 一个统计周期内的预算使用情况
+type Window struct {
+	Used      Usage      `json:"used"`
+	Limits    Limits     `json:"limits"`
+	Remaining Remaining  `json:"remaining"`
+	ResetAt   *time.Time `json:"reset_at,omitempty"`
+}
+
+// 剩余预算，未限制的项省略
+type Remaining struct {
+	Calls   *int `json:"calls,omitempty"`
+	Results *int `json:"results,omitempty"`
+	Points  *int `json:"points,omitempty"`
+}
+
+// 当前的预算使用情况，未设置预算时返回 nil
+func (g *Guard) Report() *Report {
+	if g == nil {
+		return nil
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.rollover()
+
+	report := &Report{}
+	if !g.process.Unlimited() {
+		report.Process = window(g.process, g.processUsed, &report.Exhausted)
+	}
+	if !g.daily.Unlimited() {
+		report.Daily = window(g.daily, g.dailyUsed, &report.Exhausted)
+		resetAt := g.resetAt()
+		report.Daily.ResetAt = &resetAt
+	}
+	return report
+}
+
+func window(limits Limits, used Usage, exhausted *bool) *Window {
+	w := &Window{Used: used, Limits: limits}
+	remaining := []**int{&w.Remaining.Calls, &w.Remaining.Results, &w.Remaining.Points}
+	for i, item := range limits.items(used) {
+		if item.limit == 0 {
+			continue
+		}
+		left := max(item.limit-item.used, 0)
+		if left == 0 {
+			*exhausted = true
+		}
+		*remaining[i] = &left
+	}
+	return w
+}
+
+var usageSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"calls":   map[string]interface{}{"type": "integer", "description": "API 请求次数"},
+		"results": map[string]interface{}{"type": "integer", "description": "取回的结果数"},
+		"points":  map[string]interface{}{"type": "integer", "description": "消耗的积分"},
+	},
+}
+
+var windowSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"used":      usageSchema,
+		"limits":    usageSchema,
+		"remaining": usageSchema,
+		"reset_at":  map[string]interface{}{"type": "string", "format": "date-time"},
+	},
+}
+
+// 工具结果中 budget 的 JSON Schema
+var ResultSchema = map[string]interface{}{
+	"type":        "object",
+	"description": "用量预算的使用情况，limits 中为 0 的项不限制，remaining 中省略不限制的项；未配置预算时省略",
+	"properties": map[string]interface{}{
+		"exhausted": map[string]interface{}{"type": "boolean", "description": "是否有任一项预算已用尽，用尽后的搜索请求会被拒绝"},
+		"process":   windowSchema,
+		"daily":     windowSchema,
+	},
+}
@@ -0,0 +1,123 @@
+package budget
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNilGuard(t *testing.T) {
+	g := New(Limits{}, Limits{})
+	if g != nil {
+		t.Fatal("未设置上限时应返回 nil")
+	}
+	r, err := g.Reserve(Usage{Calls: 1, Results: 10000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Settle(Usage{Calls: 1})
+	if g.Report() != nil {
+		t.Fatal("未设置预算时 Report 应为 nil")
+	}
+}
+
+func TestReserveAndSettle(t *testing.T) {
+	g := New(Limits{Results: 150}, Limits{Calls: 3})
+
+	r, err := g.Reserve(Usage{Calls: 1, Results: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 预留期间其余请求按预计用量计算
+	if _, err := g.Reserve(Usage{Calls: 1, Results: 100}); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("预计用量超出剩余预算时错误为 %v", err)
+	}
+	// 结算后按实际用量计算
+	r.Settle(Usage{Calls: 1, Results: 40})
+	r.Settle(Usage{Calls: 1, Results: 100})
+	r, err = g.Reserve(Usage{Calls: 1, Results: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Settle(Usage{Calls: 1, Results: 100})
+
+	report := g.Report()
+	if report.Process.Used.Results != 140 || *report.Process.Remaining.Results != 10 || report.Process.Remaining.Calls != nil {
+		t.Fatalf("本进程预算为 %+v", report.Process)
+	}
+	if report.Daily.Used.Calls != 2 || *report.Daily.Remaining.Calls != 1 || report.Daily.ResetAt == nil || report.Exhausted {
+		t.Fatalf("今日预算为 %+v", report.Daily)
+	}
+
+	_, err = g.Reserve(Usage{Calls: 1, Results: 100})
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || exceeded.Scope != "本进程" || exceeded.Item != "结果数" {
+		t.Fatalf("错误为 %v", err)
+	}
+
+	r, _ = g.Reserve(Usage{Calls: 1, Results: 10})
+	r.Settle(Usage{Calls: 1, Results: 10})
+	if !g.Report().Exhausted {
+		t.Fatal("用尽后 exhausted 应为 true")
+	}
+	// 用尽后即使预计用量为 0 也拒绝
+	if _, err := g.Reserve(Usage{}); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("用尽后错误为 %v", err)
+	}
+}
+
+func TestDailyRollover(t *testing.T) {
+	now := time.Date(2024, 6, 1, 23, 0, 0, 0, time.Local)
+	g := New(Limits{}, Limits{Points: 10})
+	g.now = func() time.Time { return now }
+
+	r, err := g.Reserve(Usage{Points: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Settle(Usage{Points: 10})
+	_, err = g.Reserve(Usage{})
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) || !exceeded.ResetAt.Equal(time.Date(2024, 6, 2, 0, 0, 0, 0, time.Local)) {
+		t.Fatalf("错误为 %v", err)
+	}
+
+	now = now.Add(2 * time.Hour)
+	r, err = g.Reserve(Usage{Points: 5})
+	if err != nil {
+		t.Fatalf("跨天后应重新计数: %v", err)
+	}
+	r.Settle(Usage{Points: 3})
+	if used := g.Report().Daily.Used.Points; used != 3 {
+		t.Fatalf("今日已用 %d 积分，期望 3", used)
+	}
+}
+
+func TestBudgetFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "budget", "fofa.json")
+	daily := Limits{Results: 100}
+
+	g, err := Open(Limits{}, daily, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := g.Reserve(Usage{Calls: 1, Results: 60})
+	r.Settle(Usage{Calls: 1, Results: 60})
+
+	// 重启后继续计数
+	g, err = Open(Limits{}, daily, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Reserve(Usage{Calls: 1, Results: 60}); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("重启后错误为 %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	err := Limits{Points: -1}.Validate("fofa.budget.daily")
+	if err == nil || err.Error() != "配置项 fofa.budget.daily_points 不能小于 0，当前为 -1" {
+		t.Fatalf("错误为 %v", err)
+	}
+}
@@ -0,0 +1,221 @@
+// Package cache 缓存搜索引擎 API 的响应，避免重复查询消耗积分。
+//
+// 缓存模式通过 context 传递，由工具的 cache 参数决定：
+//
+//	默认     命中缓存时直接返回，否则请求 API 并写入缓存
+//	bypass   不读也不写缓存
+//	refresh  忽略已有缓存，请求 API 后写入缓存
+//	only     只读缓存，未命中时返回 ErrMiss，不请求 API
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// 缓存模式
+type Mode string
+
+const (
+	ModeDefault Mode = ""
+	ModeBypass  Mode = "bypass"
+	ModeRefresh Mode = "refresh"
+	ModeOnly    Mode = "only"
+)
+
+// cache=only 时缓存未命中
+var ErrMiss = errors.New("缓存中没有该查询的结果（cache=only 时不会请求 API）")
+
+// 解析工具的 cache 参数，空字符串为默认模式
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(s); mode {
+	case ModeDefault, ModeBypass, ModeRefresh, ModeOnly:
+		return mode, nil
+	}
+	return "", fmt.Errorf("cache参数必须为 bypass、refresh 或 only，当前为 %q", s)
+}
+
+// 由各部分生成缓存键，各部分按 JSON 编码后取 SHA-256
+func Key(parts ...interface{}) string {
+	data, _ := json.Marshal(parts)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// 响应缓存，nil 表示未启用缓存
+type Cache struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// 创建缓存，条目在 ttl 后过期
+func New(store Store, ttl time.Duration) *Cache {
+	return &Cache{store: store, ttl: ttl, now: time.Now}
+}
+
+// 按 ctx 中的缓存模式读取缓存或调用 fetch，fetch 成功时写入缓存。
+// 存储读写失败时视为未命中，不影响查询本身
+func (c *Cache) Fetch(ctx context.Context, key string, fetch func() ([]byte, error)) ([]byte, error) {
+	status := statusFrom(ctx)
+	mode := status.mode()
+
+	if c == nil {
+		if mode == ModeOnly {
+			return nil, fmt.Errorf("未启用缓存，不能使用 cache=only")
+		}
+		status.record(false, time.Time{})
+		return fetch()
+	}
+
+	if mode == ModeDefault || mode == ModeOnly {
+		if entry, ok, err := c.store.Get(key); err == nil && ok && c.now().Before(entry.ExpiresAt) {
+			status.record(true, entry.StoredAt)
+			return entry.Value, nil
+		}
+		if mode == ModeOnly {
+			return nil, ErrMiss
+		}
+	}
+
+	status.record(false, time.Time{})
+	value, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	if mode != ModeBypass {
+		now := c.now()
+		c.store.Set(key, Entry{Value: value, StoredAt: now, ExpiresAt: now.Add(c.ttl)})
+	}
+	return value, nil
+}
+
+// 不读也不写缓存地调用 fetch，用于结果不应被复用的请求（例如要求 API 忽略其自身缓存的查询）。
+// 仍计入 ctx 的缓存使用情况；cache=only 时返回错误
+func (c *Cache) Bypass(ctx context.Context, fetch func() ([]byte, error)) ([]byte, error) {
+	status := statusFrom(ctx)
+	if status.mode() == ModeOnly {
+		return nil, fmt.Errorf("该请求的结果不会缓存，不能使用 cache=only")
+	}
+	status.record(false, time.Time{})
+	return fetch()
+}
+
+type statusKey struct{}
+
+// 一次工具调用中的缓存使用情况，同一调用中的多次请求（例如自动翻页）汇总在一起
+type Status struct {
+	Mode Mode
+
+	mu       sync.Mutex
+	hits     int
+	misses   int
+	cachedAt time.Time // 命中的缓存中最早的写入时间
+}
+
+// 返回携带缓存模式的 context，以及用于读取缓存使用情况的 Status
+func WithMode(ctx context.Context, mode Mode) (context.Context, *Status) {
+	status := &Status{Mode: mode}
+	return context.WithValue(ctx, statusKey{}, status), status
+}
+
+// 读取工具参数中的 cache，返回携带缓存模式的 context
+func FromArgs(ctx context.Context, args map[string]interface{}) (context.Context, *Status, error) {
+	raw, _ := args["cache"].(string)
+	mode, err := ParseMode(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx, status := WithMode(ctx, mode)
+	return ctx, status, nil
+}
+
+func statusFrom(ctx context.Context) *Status {
+	if status, ok := ctx.Value(statusKey{}).(*Status); ok {
+		return status
+	}
+	return nil
+}
+
+// 返回 ctx 中的缓存模式，未设置时为默认模式
+func ModeFrom(ctx context.Context) Mode {
+	return statusFrom(ctx).mode()
+}
+
+func (s *Status) mode() Mode {
+	if s == nil {
+		return ModeDefault
+	}
+	return s.Mode
+}
+
+func (s *Status) record(hit bool, storedAt time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !hit {
+		s.misses++
+		return
+	}
+	s.hits++
+	if s.cachedAt.IsZero() || storedAt.Before(s.cachedAt) {
+		s.cachedAt = storedAt
+	}
+}
+
+// 工具结果中的缓存信息
+type Result struct {
+	Hit      bool       `json:"hit"`  // 所有请求都命中缓存
+	Mode     string     `json:"mode"` // 使用的缓存模式，默认为 default
+	Hits     int        `json:"hits"`
+	Misses   int        `json:"misses"`
+	CachedAt *time.Time `json:"cached_at,omitempty"` // 命中的缓存中最早的写入时间
+}
+
+// 汇总缓存使用情况
+func (s *Status) Result() *Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := &Result{
+		Hit:    s.hits > 0 && s.misses == 0,
+		Mode:   string(s.Mode),
+		Hits:   s.hits,
+		Misses: s.misses,
+	}
+	if result.Mode == "" {
+		result.Mode = "default"
+	}
+	if !s.cachedAt.IsZero() {
+		cachedAt := s.cachedAt
+		result.CachedAt = &cachedAt
+	}
+	return result
+}
+
+// 工具参数中 cache 的 JSON Schema
+var ArgumentSchema = map[string]interface{}{
+	"type":        "string",
+	"enum":        []string{"bypass", "refresh", "only"},
+	"description": "缓存模式：不设置时优先使用缓存的结果；bypass 不读也不写缓存；refresh 重新查询并更新缓存；only 只返回缓存的结果，未命中时报错且不消耗积分",
+}
+
+// 工具结果中 cache 的 JSON Schema
+var ResultSchema = map[string]interface{}{
+	"type":        "object",
+	"description": "缓存使用情况",
+	"properties": map[string]interface{}{
+		"hit":       map[string]interface{}{"type": "boolean", "description": "结果是否全部来自缓存"},
+		"mode":      map[string]interface{}{"type": "string"},
+		"hits":      map[string]interface{}{"type": "integer", "description": "命中缓存的请求数"},
+		"misses":    map[string]interface{}{"type": "integer", "description": "实际请求 API 的次数"},
+		"cached_at": map[string]interface{}{"type": "string", "format": "date-time", "description": "命中的缓存中最早的写入时间"},
+	},
+}
@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFetchModes(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := New(NewMemoryStore(), time.Minute)
+	c.now = func() time.Time { return now }
+
+	calls := 0
+	fetch := func() ([]byte, error) {
+		calls++
+		return []byte{byte('0' + calls)}, nil
+	}
+	get := func(mode Mode) (string, *Result, error) {
+		ctx, status := WithMode(context.Background(), mode)
+		value, err := c.Fetch(ctx, "k", fetch)
+		return string(value), status.Result(), err
+	}
+
+	if _, _, err := get(ModeOnly); !errors.Is(err, ErrMiss) || calls != 0 {
+		t.Fatalf("only 未命中时应返回 ErrMiss 且不请求: err=%v calls=%d", err, calls)
+	}
+	if v, r, _ := get(ModeDefault); v != "1" || r.Hit || r.Misses != 1 {
+		t.Fatalf("首次查询: %s %+v", v, r)
+	}
+	if v, r, _ := get(ModeDefault); v != "1" || !r.Hit || !r.CachedAt.Equal(now) {
+		t.Fatalf("再次查询应命中缓存: %s %+v", v, r)
+	}
+	if v, r, _ := get(ModeBypass); v != "2" || r.Hit {
+		t.Fatalf("bypass: %s %+v", v, r)
+	}
+	if v, _, _ := get(ModeOnly); v != "1" {
+		t.Fatalf("bypass 不应写入缓存，得到 %s", v)
+	}
+	if v, _, _ := get(ModeRefresh); v != "3" {
+		t.Fatalf("refresh: %s", v)
+	}
+	if v, r, _ := get(ModeOnly); v != "3" || r.Mode != "only" {
+		t.Fatalf("refresh 后的缓存: %s %+v", v, r)
+	}
+
+	// 过期后重新请求
+	now = now.Add(2 * time.Minute)
+	if v, r, _ := get(ModeDefault); v != "4" || r.Hit {
+		t.Fatalf("过期后: %s %+v", v, r)
+	}
+}
+
+func TestFetchErrorNotCached(t *testing.T) {
+	c := New(NewMemoryStore(), time.Minute)
+	if _, err := c.Fetch(context.Background(), "k", func() ([]byte, error) { return nil, errors.New("失败") }); err == nil {
+		t.Fatal("期望错误")
+	}
+	value, err := c.Fetch(context.Background(), "k", func() ([]byte, error) { return []byte("ok"), nil })
+	if err != nil || string(value) != "ok" {
+		t.Fatalf("失败的结果不应被缓存: %s %v", value, err)
+	}
+}
+
+func TestStatusAggregates(t *testing.T) {
+	c := New(NewMemoryStore(), time.Minute)
+	ctx, status := WithMode(context.Background(), ModeDefault)
+	fetch := func() ([]byte, error) { return []byte("v"), nil }
+	c.Fetch(ctx, "page1", fetch)
+	c.Fetch(ctx, "page1", fetch)
+	c.Fetch(ctx, "page2", fetch)
+	if r := status.Result(); r.Hit || r.Hits != 1 || r.Misses != 2 || r.Mode != "default" {
+		t.Fatalf("汇总结果为 %+v", r)
+	}
+
+	var disabled *Cache
+	if _, err := disabled.Fetch(ctx, "k", fetch); err != nil {
+		t.Fatal(err)
+	}
+	onlyCtx, _ := WithMode(context.Background(), ModeOnly)
+	if _, err := disabled.Fetch(onlyCtx, "k", fetch); err == nil {
+		t.Fatal("未启用缓存时 only 应返回错误")
+	}
+}
+
+func TestBypass(t *testing.T) {
+	c := New(NewMemoryStore(), time.Minute)
+	ctx, status := WithMode(context.Background(), ModeDefault)
+	if _, err := c.Bypass(ctx, func() ([]byte, error) { return []byte("v"), nil }); err != nil {
+		t.Fatal(err)
+	}
+	if r := status.Result(); r.Misses != 1 || c.store.(*MemoryStore).Len() != 0 {
+		t.Fatalf("Bypass 应计为未命中且不写入缓存: %+v", r)
+	}
+
+	onlyCtx, _ := WithMode(context.Background(), ModeOnly)
+	if _, err := c.Bypass(onlyCtx, func() ([]byte, error) { return []byte("v"), nil }); err == nil {
+		t.Fatal("cache=only 时 Bypass 应返回错误")
+	}
+}
+
+func TestMemoryStoreEviction(t *testing.T) {
+	store := NewMemoryStore()
+	store.MaxEntries = 2
+	entry := Entry{Value: []byte("v"), ExpiresAt: time.Now().Add(time.Hour)}
+	store.Set("a", entry)
+	store.Set("b", entry)
+	store.Get("a") // a 成为最近使用的条目
+	store.Set("c", entry)
+
+	if store.Len() != 2 {
+		t.Fatalf("条目数为 %d，期望 2", store.Len())
+	}
+	if _, ok, _ := store.Get("b"); ok {
+		t.Fatal("最久未使用的 b 应被淘汰")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := store.Get(key); !ok {
+			t.Fatalf("%s 不应被淘汰", key)
+		}
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	c, err := Open(BackendDisk, dir, "test", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Key("fofa", "search", `app="nginx"`, 1, 100)
+	if _, err := c.Fetch(context.Background(), key, func() ([]byte, error) { return []byte(`{"size":1}`), nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新的实例（模拟重启）读取同一目录
+	reopened, _ := Open(BackendDisk, dir, "test", time.Hour)
+	ctx, status := WithMode(context.Background(), ModeOnly)
+	value, err := reopened.Fetch(ctx, key, nil)
+	if err != nil || string(value) != `{"size":1}` || !status.Result().Hit {
+		t.Fatalf("重启后读取缓存: %s %v", value, err)
+	}
+
+	store := reopened.store.(*DiskStore)
+	store.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if _, ok, _ := store.Get(key); ok {
+		t.Fatal("过期条目不应返回")
+	}
+	if _, err := os.Stat(store.path(key)); !os.IsNotExist(err) {
+		t.Fatalf("过期条目的文件应被删除: %v", err)
+	}
+}
+
+func TestDiskStorePrune(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	store.Set(Key("expired"), Entry{Value: []byte("v"), ExpiresAt: now.Add(-time.Minute)})
+	store.Set(Key("live"), Entry{Value: []byte("v"), ExpiresAt: now.Add(time.Hour)})
+	os.WriteFile(filepath.Join(dir, "notes.json"), []byte("其他文件"), 0o600)
+
+	// 打开时删除过期条目，不影响目录中的其他文件
+	reopened, err := NewDiskStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 1 {
+		t.Fatalf("条目数为 %d，期望 1", reopened.Len())
+	}
+	if _, err := os.Stat(reopened.path(Key("expired"))); !os.IsNotExist(err) {
+		t.Fatalf("过期条目的文件应被删除: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.json")); err != nil {
+		t.Fatalf("其他文件不应被删除: %v", err)
+	}
+
+	// 超出上限时淘汰最久未使用的条目
+	reopened.MaxEntries = 2
+	entry := Entry{Value: []byte("v"), ExpiresAt: now.Add(time.Hour)}
+	reopened.Set(Key("b"), entry)
+	os.Chtimes(reopened.path(Key("b")), now.Add(-time.Hour), now.Add(-time.Hour))
+	reopened.Set(Key("c"), entry)
+	if reopened.Len() != 2 {
+		t.Fatalf("条目数为 %d，期望 2", reopened.Len())
+	}
+	if _, ok, _ := reopened.Get(Key("b")); ok {
+		t.Fatal("最久未使用的 b 应被淘汰")
+	}
+	for _, key := range []string{"live", "c"} {
+		if _, ok, _ := reopened.Get(Key(key)); !ok {
+			t.Fatalf("%s 不应被淘汰", key)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	for _, backend := range []string{BackendNone, BackendMemory} {
+		if _, err := Open(backend, "", "test", time.Minute); err != nil {
+			t.Errorf("%s: %v", backend, err)
+		}
+	}
+	if c, _ := Open(BackendMemory, "", "test", 0); c != nil {
+		t.Error("ttl 为 0 时不应启用缓存")
+	}
+	if _, err := Open("redis", "", "test", time.Minute); err == nil {
+		t.Error("未知后端应返回错误")
+	}
+}
@@ -0,0 +1,298 @@
+package cache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 缓存条目
+type Entry struct {
+	Value     []byte    `json:"value"`
+	StoredAt  time.Time `json:"stored_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// 缓存存储后端
+type Store interface {
+	Get(key string) (Entry, bool, error)
+	Set(key string, entry Entry) error
+}
+
+// 内存存储，进程退出后失效。条目数超过 MaxEntries 时淘汰最久未使用的条目，
+// 过期的条目不再被读取，随后同样被淘汰
+type MemoryStore struct {
+	// 最多保存的条目数，为 0 时使用 DefaultMaxEntries
+	MaxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // 按最近使用排列，队首为最近使用的条目
+}
+
+// 内存存储默认最多保存的条目数
+const DefaultMaxEntries = 1024
+
+type memoryItem struct {
+	key   string
+	entry Entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*list.Element), order: list.New()}
+}
+
+func (s *MemoryStore) Get(key string) (Entry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.entries[key]
+	if !ok {
+		return Entry{}, false, nil
+	}
+	s.order.MoveToFront(elem)
+	return elem.Value.(*memoryItem).entry, true, nil
+}
+
+func (s *MemoryStore) Set(key string, entry Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.entries[key]; ok {
+		elem.Value.(*memoryItem).entry = entry
+		s.order.MoveToFront(elem)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&memoryItem{key: key, entry: entry})
+
+	max := s.MaxEntries
+	if max <= 0 {
+		max = DefaultMaxEntries
+	}
+	for s.order.Len() > max {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// 条目数
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(elem *list.Element) {
+	s.order.Remove(elem)
+	delete(s.entries, elem.Value.(*memoryItem).key)
+}
+
+// 磁盘存储，每个条目一个 JSON 文件，服务重启后仍然有效。条目数超过 MaxEntries 时
+// 先删除过期的条目，仍超出时按最近使用时间淘汰最久未使用的条目
+type DiskStore struct {
+	// 最多保存的条目数，为 0 时使用 DefaultMaxEntries
+	MaxEntries int
+
+	dir string
+	now func() time.Time
+
+	mu    sync.Mutex
+	count int // 目录中的条目数，由 prune 重新统计
+}
+
+// 临时文件保留的时长，超过后视为写入中途退出的残留文件
+const staleTempAge = time.Minute
+
+// 创建磁盘存储，目录不存在时自动创建。打开时删除已过期的条目和残留的临时文件
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	s := &DiskStore{dir: dir, now: time.Now}
+	if err := s.prune(); err != nil {
+		return nil, fmt.Errorf("清理缓存目录失败: %w", err)
+	}
+	return s, nil
+}
+
+func (s *DiskStore) path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// 读取条目，已过期的条目会被删除；命中时更新文件的修改时间，作为最近使用时间
+func (s *DiskStore) Get(key string) (Entry, bool, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return Entry{}, false, nil
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		// 损坏的条目直接删除
+		os.Remove(s.path(key))
+		return Entry{}, false, nil
+	}
+	if !s.now().Before(entry.ExpiresAt) {
+		os.Remove(s.path(key))
+		return Entry{}, false, nil
+	}
+	now := s.now()
+	os.Chtimes(s.path(key), now, now)
+	return entry, true, nil
+}
+
+// 条目数
+func (s *DiskStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// 删除过期或损坏的条目和残留的临时文件，条目数仍超过上限时删除最久未使用的条目。
+// 只处理缓存键命名的文件，目录中的其他文件不受影响
+func (s *DiskStore) prune() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	type item struct {
+		path string
+		used time.Time
+	}
+	var live []item
+	now := s.now()
+	for _, f := range files {
+		name := f.Name()
+		path := filepath.Join(s.dir, name)
+		info, err := f.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if key, _, ok := strings.Cut(name, "."); ok && isKey(key) && strings.HasSuffix(name, ".tmp") {
+			if now.Sub(info.ModTime()) > staleTempAge {
+				os.Remove(path)
+			}
+			continue
+		}
+		key, ok := strings.CutSuffix(name, ".json")
+		if !ok || !isKey(key) {
+			continue
+		}
+		var entry Entry
+		data, err := os.ReadFile(path)
+		if err != nil || json.Unmarshal(data, &entry) != nil || !now.Before(entry.ExpiresAt) {
+			os.Remove(path)
+			continue
+		}
+		live = append(live, item{path: path, used: info.ModTime()})
+	}
+
+	if max := s.maxEntries(); len(live) > max {
+		sort.Slice(live, func(i, j int) bool { return live[i].used.Before(live[j].used) })
+		for _, it := range live[:len(live)-max] {
+			os.Remove(it.path)
+		}
+		live = live[len(live)-max:]
+	}
+	s.mu.Lock()
+	s.count = len(live)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *DiskStore) maxEntries() int {
+	if s.MaxEntries > 0 {
+		return s.MaxEntries
+	}
+	return DefaultMaxEntries
+}
+
+// 是否为 Key 生成的缓存键（SHA-256 的十六进制表示）
+func isKey(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// 写入条目，先写临时文件再重命名，避免并发读取到不完整的内容
+func (s *DiskStore) Set(key string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	_, statErr := os.Stat(s.path(key))
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		return err
+	}
+	if !errors.Is(statErr, os.ErrNotExist) {
+		return nil
+	}
+
+	s.mu.Lock()
+	s.count++
+	full := s.count > s.maxEntries()
+	s.mu.Unlock()
+	if full {
+		return s.prune()
+	}
+	return nil
+}
+
+// 缓存后端
+const (
+	BackendNone   = "none"
+	BackendMemory = "memory"
+	BackendDisk   = "disk"
+)
+
+// 按配置创建缓存：backend 为 none 或 ttl 不大于 0 时返回 nil（不缓存），
+// disk 后端的 dir 为空时使用用户缓存目录下的 securitymcp-hub/<name>
+func Open(backend, dir, name string, ttl time.Duration) (*Cache, error) {
+	if ttl <= 0 {
+		return nil, nil
+	}
+	switch backend {
+	case BackendNone:
+		return nil, nil
+	case BackendMemory, "":
+		return New(NewMemoryStore(), ttl), nil
+	case BackendDisk:
+		if dir == "" {
+			base, err := os.UserCacheDir()
+			if err != nil {
+				return nil, fmt.Errorf("无法确定缓存目录，请设置 cache_dir: %w", err)
+			}
+			dir = filepath.Join(base, "securitymcp-hub", name)
+		}
+		store, err := NewDiskStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		return New(store, ttl), nil
+	}
+	return nil, fmt.Errorf("未知的缓存后端 %q，可选值：memory、disk、none", backend)
+}
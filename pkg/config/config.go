@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 从 YAML 文件加载配置到 v，path 为空时保留 v 中的默认值。
+// 未知的配置项视为错误，避免拼写错误被静默忽略
+func Load(path string, v interface{}) error {
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取配置文件失败: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+	return nil
+}
+
+// 用环境变量覆盖配置，字段通过 env 标签声明对应的环境变量，
+// 例如 `env:"FOFA_BASE_URL"`。支持 string、int 和 bool 字段以及嵌套结构体
+func ApplyEnv(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: ApplyEnv 需要结构体指针")
+	}
+	return applyEnv(rv.Elem())
+}
+
+func applyEnv(rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+
+		if field.Type.Kind() == reflect.Struct {
+			if err := applyEnv(value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := field.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		raw = strings.TrimSpace(raw)
+
+		switch field.Type.Kind() {
+		case reflect.String:
+			value.SetString(raw)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是有效的整数: %q", name, raw)
+			}
+			value.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(raw)
+			if err != nil {
+				return fmt.Errorf("环境变量 %s 不是有效的布尔值: %q", name, raw)
+			}
+			value.SetBool(b)
+		default:
+			return fmt.Errorf("config: 字段 %s 的类型 %s 不支持环境变量覆盖", field.Name, field.Type)
+		}
+	}
+	return nil
+}
+
+// 校验 http/https 地址，key 为配置项名称，用于错误信息
+func ValidateHTTPURL(key, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("配置项 %s 无效: %v", key, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("配置项 %s 必须是 http:// 或 https:// 开头的完整地址，当前为 %q", key, raw)
+	}
+	return nil
+}
+
+// 校验代理地址，支持 http、https 和 socks5，空值表示不配置代理
+func ValidateProxyURL(key, raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("配置项 %s 无效: %v", key, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("配置项 %s 的协议必须是 http、https 或 socks5，当前为 %q", key, raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("配置项 %s 缺少代理主机，当前为 %q", key, raw)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testConfig struct {
+	API struct {
+		BaseURL string `yaml:"base_url" env:"TEST_BASE_URL"`
+		Timeout int    `yaml:"timeout" env:"TEST_TIMEOUT"`
+		Debug   bool   `yaml:"debug" env:"TEST_DEBUG"`
+	} `yaml:"api"`
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadAndApplyEnv(t *testing.T) {
+	var cfg testConfig
+	cfg.API.Timeout = 30
+
+	path := writeConfig(t, "api:\n  base_url: https://file.example\n")
+	if err := Load(path, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.API.BaseURL != "https://file.example" || cfg.API.Timeout != 30 {
+		t.Fatalf("配置文件未正确合并默认值: %+v", cfg)
+	}
+
+	t.Setenv("TEST_BASE_URL", "https://env.example")
+	t.Setenv("TEST_TIMEOUT", "5")
+	t.Setenv("TEST_DEBUG", "true")
+	if err := ApplyEnv(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.API.BaseURL != "https://env.example" || cfg.API.Timeout != 5 || !cfg.API.Debug {
+		t.Fatalf("环境变量未覆盖配置: %+v", cfg)
+	}
+
+	t.Setenv("TEST_TIMEOUT", "abc")
+	if err := ApplyEnv(&cfg); err == nil || !strings.Contains(err.Error(), "TEST_TIMEOUT") {
+		t.Fatalf("无效整数应报告环境变量名，实际: %v", err)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	var cfg testConfig
+	path := writeConfig(t, "api:\n  base_ur: https://typo.example\n")
+	if err := Load(path, &cfg); err == nil || !strings.Contains(err.Error(), "base_ur") {
+		t.Fatalf("未知配置项应报错，实际: %v", err)
+	}
+}
+
+func TestLoadEmptyPath(t *testing.T) {
+	var cfg testConfig
+	cfg.API.Timeout = 30
+	if err := Load("", &cfg); err != nil || cfg.API.Timeout != 30 {
+		t.Fatalf("空路径应保留默认值: %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,119 @@
+// Package dork 解析 FOFA 和 ZoomEye 的查询语句，并在两者之间相互翻译。
+//
+// 两个引擎的查询语法结构相同：
+//
+//	query     = or
+//	or        = and { "||" and }
+//	and       = primary { "&&" primary }
+//	primary   = "(" or ")" | condition | string
+//	condition = key op value
+//	value     = string | word
+//
+// string 为双引号字符串，支持 \" 和 \\ 转义；单独的 string 表示全文搜索。
+// 两者的区别在于支持的字段和运算符，由 Dialect 描述
+package dork
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 查询语法树节点
+type Expr interface {
+	// 返回规范化的查询语句
+	String() string
+}
+
+// 以 && 连接的子表达式
+type AndExpr struct {
+	Terms []Expr
+}
+
+// 以 || 连接的子表达式
+type OrExpr struct {
+	Terms []Expr
+}
+
+// 查询条件，Key 为空时表示全文搜索
+type Condition struct {
+	Key    string
+	Op     string
+	Value  string
+	Quoted bool // 值是否使用双引号包裹
+	Pos    int  // 条件在查询语句中的位置（从1开始的字符序号）
+}
+
+func (e *AndExpr) String() string {
+	parts := make([]string, len(e.Terms))
+	for i, term := range e.Terms {
+		if _, ok := term.(*OrExpr); ok {
+			parts[i] = "(" + term.String() + ")"
+		} else {
+			parts[i] = term.String()
+		}
+	}
+	return strings.Join(parts, " && ")
+}
+
+func (e *OrExpr) String() string {
+	parts := make([]string, len(e.Terms))
+	for i, term := range e.Terms {
+		parts[i] = term.String()
+	}
+	return strings.Join(parts, " || ")
+}
+
+func (c *Condition) String() string {
+	if c.Key == "" {
+		return quoteValue(c.Value)
+	}
+	return c.Key + c.Op + quoteValue(c.Value)
+}
+
+// 用双引号包裹值并转义其中的双引号和反斜杠
+func quoteValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+func (e *AndExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"type": "and", "terms": e.Terms})
+}
+
+func (e *OrExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{"type": "or", "terms": e.Terms})
+}
+
+func (c *Condition) MarshalJSON() ([]byte, error) {
+	if c.Key == "" {
+		return json.Marshal(map[string]interface{}{"type": "keyword", "value": c.Value, "pos": c.Pos})
+	}
+	return json.Marshal(map[string]interface{}{"type": "condition", "key": c.Key, "op": c.Op, "value": c.Value, "pos": c.Pos})
+}
+
+// 查询语法错误，Pos 为出错位置（从1开始的字符序号）
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("第 %d 个字符处: %s", e.Pos, e.Msg)
+}
+
+// 按顺序访问语法树中的所有条件
+func walkConditions(expr Expr, fn func(*Condition)) {
+	switch e := expr.(type) {
+	case *AndExpr:
+		for _, term := range e.Terms {
+			walkConditions(term, fn)
+		}
+	case *OrExpr:
+		for _, term := range e.Terms {
+			walkConditions(term, fn)
+		}
+	case *Condition:
+		fn(e)
+	}
+}
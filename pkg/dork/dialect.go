@@ -0,0 +1,159 @@
+package dork
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 查询语言方言：支持的字段、运算符和值格式
+type Dialect struct {
+	Name string // 引擎名称，用于错误信息
+
+	keys        []string // 按字母顺序排列，使字段建议的顺序稳定
+	keySet      map[string]bool
+	ops         map[string]bool
+	numericKeys map[string]bool
+	booleanKeys map[string]bool
+	dateKeys    map[string]bool
+
+	// 检查字段名是否为旧版语法，返回提示信息；没有旧版语法时为 nil
+	legacyHint func(word string) string
+	// 不支持的运算符的替代写法
+	opHints map[string]string
+}
+
+var (
+	numericRe   = regexp.MustCompile(`^\d+$`)
+	dateValueRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+func newDialect(d Dialect, keys []string) *Dialect {
+	d.keys = append([]string(nil), keys...)
+	sort.Strings(d.keys)
+	d.keySet = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		d.keySet[key] = true
+	}
+	return &d
+}
+
+// 判断是否为支持的查询字段
+func (d *Dialect) IsKey(key string) bool {
+	return d.keySet[key]
+}
+
+// 解析查询语句，返回语法树；语法错误返回 *SyntaxError
+func (d *Dialect) Parse(query string) (Expr, error) {
+	tokens, err := tokenize(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, &SyntaxError{1, "查询语句为空"}
+	}
+
+	p := &parser{dialect: d, tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		if t.kind == tokenRParen {
+			return nil, &SyntaxError{t.pos, "多余的右括号"}
+		}
+		return nil, p.unexpected(t, "此处应为 && 或 ||")
+	}
+	return expr, nil
+}
+
+// 检查语法正确但可能不符合预期的写法，返回提示信息
+func (d *Dialect) Lint(expr Expr) []string {
+	var warnings []string
+	walkConditions(expr, func(c *Condition) {
+		if c.Key == "" {
+			return
+		}
+		switch {
+		case c.Value == "":
+			warnings = append(warnings, fmt.Sprintf("第 %d 个字符处: %s 的值为空", c.Pos, c.Key))
+		case d.numericKeys[c.Key] && !numericRe.MatchString(c.Value):
+			warnings = append(warnings, fmt.Sprintf("第 %d 个字符处: %s 的值应为数字，当前为 %q", c.Pos, c.Key, c.Value))
+		case d.booleanKeys[c.Key] && c.Value != "true" && c.Value != "false":
+			warnings = append(warnings, fmt.Sprintf("第 %d 个字符处: %s 的值应为 true 或 false，当前为 %q", c.Pos, c.Key, c.Value))
+		case d.dateKeys[c.Key] && !dateValueRe.MatchString(c.Value):
+			warnings = append(warnings, fmt.Sprintf("第 %d 个字符处: %s 的值应为 YYYY-MM-DD 格式的日期，当前为 %q", c.Pos, c.Key, c.Value))
+		}
+		if !c.Quoted {
+			warnings = append(warnings, fmt.Sprintf("第 %d 个字符处: 建议用双引号包裹 %s 的值，例如 %s", c.Pos, c.Key, c.String()))
+		}
+	})
+	return warnings
+}
+
+// 针对 AND/OR/NOT 关键字的提示
+func (d *Dialect) logicWordHint(word string) string {
+	switch word {
+	case "AND":
+		return d.Name + " 不支持 AND，请使用 &&"
+	case "OR":
+		return d.Name + " 不支持 OR，请使用 ||"
+	}
+	return d.Name + " 不支持 NOT，请使用 != 表示排除"
+}
+
+// 为未知查询字段推荐相近的字段，最多3个
+func (d *Dialect) suggestKeys(key string) []string {
+	lower := strings.ToLower(key)
+	if d.IsKey(lower) {
+		return []string{lower}
+	}
+	var suggestions []string
+	for distance := 1; distance <= 2 && len(suggestions) < 3; distance++ {
+		for _, candidate := range d.keys {
+			if len(suggestions) < 3 && EditDistance(lower, candidate) == distance {
+				suggestions = append(suggestions, candidate)
+			}
+		}
+	}
+	// 例如 app_name 推荐 app
+	for _, candidate := range d.keys {
+		if len(suggestions) < 3 && len(candidate) >= 3 && strings.HasPrefix(lower, candidate+"_") {
+			suggestions = append(suggestions, candidate)
+		}
+	}
+	return suggestions
+}
+
+// 两个字符串之间的编辑距离
+func EditDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// 按名称查找方言：fofa 或 zoomeye，不区分大小写
+func Lookup(name string) (*Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "fofa":
+		return FOFA, nil
+	case "zoomeye":
+		return ZoomEye, nil
+	}
+	return nil, fmt.Errorf("未知的搜索引擎 %q，可选值：fofa、zoomeye", name)
+}
@@ -0,0 +1,134 @@
+package dork
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseFOFA(t *testing.T) {
+	cases := map[string]string{
+		`app="Apache"`:                                  `app="Apache"`,
+		`title="a \"b\"" && port=443`:                   `title="a \"b\"" && port="443"`,
+		`(app="nginx" || app="Apache") && country="CN"`: `(app="nginx" || app="Apache") && country="CN"`,
+		`"login"`:                           `"login"`,
+		`host*="gov" && status_code!="404"`: `host*="gov" && status_code!="404"`,
+		`ip=="1.1.1.1" && (port="80" && protocol="http")`: `ip=="1.1.1.1" && port="80" && protocol="http"`,
+		`title="管理后台"||title="登录"`:                        `title="管理后台" || title="登录"`,
+	}
+
+	for query, want := range cases {
+		expr, err := FOFA.Parse(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if got := expr.String(); got != want {
+			t.Errorf("%s: 规范化为 %s，期望 %s", query, got, want)
+		}
+	}
+}
+
+func TestParseZoomEye(t *testing.T) {
+	cases := map[string]string{
+		`title="cisco vpn"`:                                      `title="cisco vpn"`,
+		`app="nginx" && country="CN"`:                            `app="nginx" && country="CN"`,
+		`(port=80 || port=443) && http.header.server!="IIS"`:     `(port="80" || port="443") && http.header.server!="IIS"`,
+		`ip=="8.8.8.8"`:                                          `ip=="8.8.8.8"`,
+		`cidr=2001:db8::/32`:                                     `cidr="2001:db8::/32"`,
+		`"login" && iconhash="f3418a443e7d841097c714d69ec4bcb8"`: `"login" && iconhash="f3418a443e7d841097c714d69ec4bcb8"`,
+	}
+	for query, want := range cases {
+		expr, err := ZoomEye.Parse(query)
+		if err != nil {
+			t.Errorf("%s: %v", query, err)
+			continue
+		}
+		if got := expr.String(); got != want {
+			t.Errorf("%s: 规范化为 %s，期望 %s", query, got, want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		dialect *Dialect
+		query   string
+		pos     int
+		msg     string
+	}{
+		{FOFA, ``, 1, "为空"},
+		{FOFA, `title="abc`, 7, "双引号未闭合"},
+		{FOFA, `app="a" AND port="80"`, 9, "FOFA 不支持 AND，请使用 &&"},
+		{FOFA, `app="a" OR port="80"`, 9, "使用 ||"},
+		{FOFA, `app_name="nginx"`, 1, "是否想使用 app"},
+		{FOFA, `titel="x"`, 1, "title"},
+		{FOFA, `app="a" & port="80"`, 9, "&&"},
+		{FOFA, `(app="a" || app="b"`, 1, "左括号未闭合"},
+		{FOFA, `app="a")`, 8, "多余的右括号"},
+		{FOFA, `app=`, 5, "缺少值"},
+		{FOFA, `app='nginx'`, 5, "双引号"},
+		{FOFA, `app="a" &&`, 11, "不完整"},
+		{FOFA, `app "a"`, 5, "应为 =、==、!= 或 *="},
+		{ZoomEye, `app="a" AND port=80`, 9, "ZoomEye 不支持 AND"},
+		{ZoomEye, `app:"nginx" +country:"CN"`, 1, "key:value 的旧语法"},
+		{ZoomEye, `+app="nginx"`, 1, "+/-"},
+		{ZoomEye, `app="a" || `, 12, "不完整"},
+		{ZoomEye, `ssl.cert.subjectcn="a"`, 1, "ssl.cert.subject.cn"},
+		{ZoomEye, `title*="x"`, 6, "ZoomEye 不支持 *= 运算符，模糊匹配请使用 ="},
+		{ZoomEye, `app "a"`, 5, "应为 =、== 或 !="},
+	}
+	for _, tc := range cases {
+		_, err := tc.dialect.Parse(tc.query)
+		syntaxErr, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%s: 期望语法错误，得到 %v", tc.query, err)
+			continue
+		}
+		if syntaxErr.Pos != tc.pos || !strings.Contains(syntaxErr.Msg, tc.msg) {
+			t.Errorf("%s: 得到 %v，期望第 %d 个字符处包含 %q", tc.query, syntaxErr, tc.pos, tc.msg)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	cases := []struct {
+		dialect *Dialect
+		query   string
+		want    []string
+	}{
+		{FOFA, `port="https" && is_domain="yes" && after="2023/01/01" && app=nginx`, []string{
+			"port 的值应为数字", "is_domain 的值应为 true 或 false", "after 的值应为 YYYY-MM-DD", `建议用双引号包裹 app 的值，例如 app="nginx"`,
+		}},
+		{ZoomEye, `port="http" && is_honeypot="1" && after="2023"`, []string{
+			"port 的值应为数字", "is_honeypot 的值应为 true 或 false", "after 的值应为 YYYY-MM-DD",
+		}},
+	}
+	for _, tc := range cases {
+		expr, err := tc.dialect.Parse(tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		warnings := strings.Join(tc.dialect.Lint(expr), "\n")
+		for _, want := range tc.want {
+			if !strings.Contains(warnings, want) {
+				t.Errorf("%s: 缺少提示 %q:\n%s", tc.query, want, warnings)
+			}
+		}
+	}
+}
+
+func TestExprJSON(t *testing.T) {
+	expr, err := FOFA.Parse(`app="a" && (port="80" || "login")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"terms":[{"key":"app","op":"=","pos":1,"type":"condition","value":"a"},{"terms":[{"key":"port","op":"=","pos":13,"type":"condition","value":"80"},{"pos":26,"type":"keyword","value":"login"}],"type":"or"}],"type":"and"}`
+	if string(data) != want {
+		t.Fatalf("JSON 为 %s", data)
+	}
+}
@@ -0,0 +1,24 @@
+package dork
+
+// FOFA 查询语法，支持 =、==、!=、*=
+var FOFA = newDialect(Dialect{
+	Name:        "FOFA",
+	ops:         map[string]bool{"=": true, "==": true, "!=": true, "*=": true},
+	numericKeys: map[string]bool{"port": true, "status_code": true, "asn": true, "port_size": true, "port_size_gt": true, "port_size_lt": true},
+	booleanKeys: map[string]bool{
+		"is_domain": true, "is_ipv6": true, "is_fraud": true, "is_honeypot": true, "is_cloud": true,
+		"cert.is_equal": true, "cert.is_valid": true, "cert.is_match": true, "cert.is_expired": true,
+	},
+	dateKeys: map[string]bool{"after": true, "before": true, "ip_after": true, "ip_before": true},
+}, []string{
+	"ip", "port", "domain", "host", "os", "server", "asn", "org", "is_domain", "is_ipv6",
+	"title", "header", "header_hash", "body", "body_hash", "js_name", "js_md5", "cname", "cname_domain",
+	"icon_hash", "status_code", "icp", "country", "region", "city",
+	"cert", "cert.subject", "cert.issuer", "cert.subject.org", "cert.subject.cn", "cert.issuer.org", "cert.issuer.cn",
+	"cert.domain", "cert.is_equal", "cert.is_valid", "cert.is_match", "cert.is_expired", "cert.sn",
+	"cert.not_after", "cert.not_before", "jarm", "tls.version", "tls.ja3s",
+	"app", "product", "product.version", "category", "type", "protocol", "base_protocol",
+	"banner", "banner_hash", "banner_fid", "fid", "after", "before", "sdk_hash", "structinfo",
+	"is_fraud", "is_honeypot", "is_cloud", "cloud_name", "ip_ports", "port_size", "port_size_gt", "port_size_lt",
+	"ip_country", "ip_region", "ip_city", "ip_after", "ip_before", "icon",
+})
@@ -0,0 +1,255 @@
+package dork
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenAnd
+	tokenOr
+	tokenOp
+	tokenString
+	tokenWord
+)
+
+type token struct {
+	kind tokenKind
+	text string // 字符串为去掉引号和转义后的内容
+	pos  int
+}
+
+// 将查询语句切分为词法单元，运算符 =、==、!=、*= 都会被识别，由方言决定是否支持
+func tokenize(query string) ([]token, error) {
+	runes := []rune(query)
+	var tokens []token
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{tokenLParen, "(", pos})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokenRParen, ")", pos})
+			i++
+		case r == '&' || r == '|':
+			if i+1 >= len(runes) || runes[i+1] != r {
+				return nil, &SyntaxError{pos, fmt.Sprintf("单个 %c 不是有效的运算符，应使用 %c%c", r, r, r)}
+			}
+			kind := tokenAnd
+			if r == '|' {
+				kind = tokenOr
+			}
+			tokens = append(tokens, token{kind, string([]rune{r, r}), pos})
+			i += 2
+		case r == '=':
+			if i+1 < len(runes) && runes[i+1] == '=' {
+				tokens = append(tokens, token{tokenOp, "==", pos})
+				i += 2
+			} else {
+				tokens = append(tokens, token{tokenOp, "=", pos})
+				i++
+			}
+		case (r == '!' || r == '*') && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, token{tokenOp, string(r) + "=", pos})
+			i += 2
+		case r == '!':
+			return nil, &SyntaxError{pos, "! 后应为 =，使用 != 表示不等于"}
+		case r == '\'':
+			return nil, &SyntaxError{pos, "查询中的值应使用双引号包裹，不支持单引号"}
+		case r == '"':
+			var value strings.Builder
+			j := i + 1
+			for ; j < len(runes) && runes[j] != '"'; j++ {
+				if runes[j] == '\\' && j+1 < len(runes) && (runes[j+1] == '"' || runes[j+1] == '\\') {
+					j++
+				}
+				value.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, &SyntaxError{pos, "双引号未闭合"}
+			}
+			tokens = append(tokens, token{tokenString, value.String(), pos})
+			i = j + 1
+		default:
+			j := i
+			for j < len(runes) && !isDelimiter(runes, j) {
+				j++
+			}
+			tokens = append(tokens, token{tokenWord, string(runes[i:j]), pos})
+			i = j
+		}
+	}
+	return append(tokens, token{tokenEOF, "", len(runes) + 1}), nil
+}
+
+// 判断第 i 个字符是否结束一个单词
+func isDelimiter(runes []rune, i int) bool {
+	r := runes[i]
+	if unicode.IsSpace(r) || strings.ContainsRune(`()&|="'`, r) {
+		return true
+	}
+	return (r == '!' || r == '*') && i+1 < len(runes) && runes[i+1] == '='
+}
+
+type parser struct {
+	dialect *Dialect
+	tokens  []token
+	pos     int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	var terms []Expr
+	for {
+		term, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		// 合并 a || (b || c)
+		if or, ok := term.(*OrExpr); ok {
+			terms = append(terms, or.Terms...)
+		} else {
+			terms = append(terms, term)
+		}
+		if p.peek().kind != tokenOr {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &OrExpr{Terms: terms}, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	var terms []Expr
+	for {
+		term, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		// 合并 a && (b && c)
+		if and, ok := term.(*AndExpr); ok {
+			terms = append(terms, and.Terms...)
+		} else {
+			terms = append(terms, term)
+		}
+		if p.peek().kind != tokenAnd {
+			break
+		}
+		p.next()
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &AndExpr{Terms: terms}, nil
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, &SyntaxError{t.pos, "左括号未闭合"}
+		}
+		return expr, nil
+	case tokenString:
+		return &Condition{Value: t.text, Quoted: true, Pos: t.pos}, nil
+	case tokenWord:
+		return p.parseCondition(t)
+	case tokenEOF:
+		return nil, &SyntaxError{t.pos, "查询语句不完整，此处应为查询条件"}
+	}
+	return nil, p.unexpected(t, "此处应为查询条件")
+}
+
+func (p *parser) parseCondition(key token) (Expr, error) {
+	d := p.dialect
+	if d.legacyHint != nil {
+		if hint := d.legacyHint(key.text); hint != "" {
+			return nil, &SyntaxError{key.pos, hint}
+		}
+	}
+
+	op := p.next()
+	if op.kind != tokenOp {
+		if upper := strings.ToUpper(key.text); upper == "AND" || upper == "OR" || upper == "NOT" {
+			return nil, &SyntaxError{key.pos, d.logicWordHint(upper)}
+		}
+		return nil, &SyntaxError{op.pos, fmt.Sprintf("字段 %s 后应为 %s", key.text, d.opList())}
+	}
+	if !d.ops[op.text] {
+		msg := fmt.Sprintf("%s 不支持 %s 运算符", d.Name, op.text)
+		if hint := d.opHints[op.text]; hint != "" {
+			msg += "，" + hint
+		}
+		return nil, &SyntaxError{op.pos, msg}
+	}
+
+	name := key.text
+	if !d.IsKey(name) {
+		msg := fmt.Sprintf("未知的查询字段 %s", name)
+		if suggestions := d.suggestKeys(name); len(suggestions) > 0 {
+			msg += fmt.Sprintf("，是否想使用 %s？", strings.Join(suggestions, "、"))
+		}
+		return nil, &SyntaxError{key.pos, msg}
+	}
+
+	value := p.next()
+	switch value.kind {
+	case tokenString:
+		return &Condition{Key: name, Op: op.text, Value: value.text, Quoted: true, Pos: key.pos}, nil
+	case tokenWord:
+		return &Condition{Key: name, Op: op.text, Value: value.text, Pos: key.pos}, nil
+	}
+	return nil, &SyntaxError{value.pos, fmt.Sprintf("%s%s 后缺少值", name, op.text)}
+}
+
+func (p *parser) unexpected(t token, expected string) error {
+	if t.kind == tokenWord {
+		if upper := strings.ToUpper(t.text); upper == "AND" || upper == "OR" || upper == "NOT" {
+			return &SyntaxError{t.pos, p.dialect.logicWordHint(upper)}
+		}
+	}
+	return &SyntaxError{t.pos, fmt.Sprintf("意外的 %q，%s", t.text, expected)}
+}
+
+// 支持的运算符列表，例如 =、==、!= 或 *=
+func (d *Dialect) opList() string {
+	var ops []string
+	for _, op := range []string{"=", "==", "!=", "*="} {
+		if d.ops[op] {
+			ops = append(ops, op)
+		}
+	}
+	if len(ops) == 1 {
+		return ops[0]
+	}
+	return strings.Join(ops[:len(ops)-1], "、") + " 或 " + ops[len(ops)-1]
+}
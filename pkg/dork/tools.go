@@ -0,0 +1,198 @@
+package dork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"securitymcp-hub/pkg/mcp"
+)
+
+// 搜索工具调用 API 前检查查询语法，避免为错误的查询消耗积分；返回值格式相关的提示
+func (d *Dialect) Check(query string) ([]string, error) {
+	expr, err := d.Parse(query)
+	if err != nil {
+		return nil, fmt.Errorf("查询语句语法错误，%w", err)
+	}
+	return d.Lint(expr), nil
+}
+
+// 查询语法检查工具的结构化输出
+type validateOutput struct {
+	Valid      bool           `json:"valid"`
+	Query      string         `json:"query"`
+	Normalized string         `json:"normalized,omitempty"`
+	AST        Expr           `json:"ast,omitempty"`
+	Error      *validateError `json:"error,omitempty"`
+	Warnings   []string       `json:"warnings,omitempty"`
+}
+
+type validateError struct {
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+var stringListSchema = map[string]interface{}{
+	"type":  "array",
+	"items": map[string]interface{}{"type": "string"},
+}
+
+// 查询语法检查工具的 outputSchema
+var ValidateOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"valid":      map[string]interface{}{"type": "boolean", "description": "语法是否正确"},
+		"query":      map[string]interface{}{"type": "string"},
+		"normalized": map[string]interface{}{"type": "string", "description": "规范化的查询语句，值统一使用双引号"},
+		"ast": map[string]interface{}{
+			"type":        "object",
+			"description": "语法树：type 为 and/or 的节点包含 terms，condition 节点包含 key、op、value，keyword 节点为全文搜索",
+		},
+		"error": map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"position": map[string]interface{}{"type": "integer", "description": "出错位置，从1开始的字符序号"},
+				"message":  map[string]interface{}{"type": "string"},
+			},
+		},
+		"warnings": stringListSchema,
+	},
+	"required": []string{"valid", "query"},
+}
+
+// 返回按方言 d 检查 query 参数语法的工具处理函数。语法错误作为结果返回，而不是工具错误
+func ValidateHandler(d *Dialect) mcp.ToolHandler {
+	return func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+		query, ok := args["query"].(string)
+		if !ok {
+			return mcp.CallToolResult{}, fmt.Errorf("query参数是必需的")
+		}
+
+		output := validateOutput{Query: query}
+		expr, err := d.Parse(query)
+		if err != nil {
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				return mcp.CallToolResult{}, err
+			}
+			output.Error = &validateError{Position: syntaxErr.Pos, Message: syntaxErr.Msg}
+			return mcp.StructuredResult(output), nil
+		}
+
+		output.Valid = true
+		output.Normalized = expr.String()
+		output.AST = expr
+		output.Warnings = d.Lint(expr)
+		return mcp.StructuredResult(output), nil
+	}
+}
+
+// translate_query 结构化输出
+type translateOutput struct {
+	Success bool   `json:"success"`
+	Query   string `json:"query"`
+	Translation
+}
+
+var translateOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success":    map[string]interface{}{"type": "boolean"},
+		"query":      map[string]interface{}{"type": "string", "description": "原查询语句"},
+		"from":       map[string]interface{}{"type": "string", "description": "源查询语法"},
+		"to":         map[string]interface{}{"type": "string", "description": "目标查询语法"},
+		"translated": map[string]interface{}{"type": "string", "description": "翻译后的查询语句"},
+		"complete":   map[string]interface{}{"type": "boolean", "description": "所有条件是否都已翻译"},
+		"untranslated": map[string]interface{}{
+			"type":        "array",
+			"description": "无法翻译而被省略的条件",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"clause":   map[string]interface{}{"type": "string"},
+					"position": map[string]interface{}{"type": "integer", "description": "条件在原查询中的位置，从1开始的字符序号"},
+					"reason":   map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+		"notes": stringListSchema,
+	},
+	"required": []string{"success", "query", "from", "to", "translated", "complete"},
+}
+
+// 注册 translate_query 工具，未指定 from 参数时按 defaultFrom 的语法翻译
+func RegisterTranslateTool(server *mcp.Server, defaultFrom *Dialect) {
+	server.RegisterTool("translate_query", `将查询语句在 FOFA 和 ZoomEye 语法之间互相翻译，不会调用任何API。
+
+支持标题、HTTP头、正文、证书、端口、国家/地区/城市、ASN、组织、域名、图标哈希、JARM 等常用字段，例如 FOFA 的 header="nginx" 翻译为 ZoomEye 的 http.header="nginx"，ip="10.0.0.0/8" 翻译为 cidr="10.0.0.0/8"。
+
+目标语法中没有对应字段的条件会被省略，并在 untranslated 中列出原条件、位置和原因；complete 为 false 时，翻译后的查询与原查询的结果范围不同，应检查 notes 中的说明。`,
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "要翻译的查询语句",
+				},
+				"from": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"fofa", "zoomeye"},
+					"description": "源查询语法，默认为 " + strings.ToLower(defaultFrom.Name),
+				},
+				"to": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"fofa", "zoomeye"},
+					"description": "目标查询语法，默认为与 from 不同的另一种",
+				},
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleTranslate(args, defaultFrom)
+		},
+		mcp.WithOutputSchema(translateOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "查询语法翻译", ReadOnlyHint: true, IdempotentHint: true}))
+}
+
+func handleTranslate(args map[string]interface{}, defaultFrom *Dialect) (mcp.CallToolResult, error) {
+	query, ok := args["query"].(string)
+	if !ok || query == "" {
+		return mcp.CallToolResult{}, fmt.Errorf("query参数是必需的")
+	}
+
+	from := defaultFrom
+	if name, ok := args["from"].(string); ok && name != "" {
+		d, err := Lookup(name)
+		if err != nil {
+			return mcp.CallToolResult{}, fmt.Errorf("from参数无效: %w", err)
+		}
+		from = d
+	}
+	to := ZoomEye
+	if from == ZoomEye {
+		to = FOFA
+	}
+	if name, ok := args["to"].(string); ok && name != "" {
+		d, err := Lookup(name)
+		if err != nil {
+			return mcp.CallToolResult{}, fmt.Errorf("to参数无效: %w", err)
+		}
+		to = d
+	}
+
+	result, err := Translate(query, from, to)
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return mcp.CallToolResult{}, fmt.Errorf("查询语句语法错误，%w", err)
+		}
+		return mcp.CallToolResult{}, err
+	}
+
+	return mcp.StructuredResult(translateOutput{
+		Success:     true,
+		Query:       query,
+		Translation: *result,
+	}), nil
+}
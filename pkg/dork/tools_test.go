@@ -0,0 +1,58 @@
+package dork
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	if _, err := ZoomEye.Check(`app:"nginx"`); err == nil || !strings.Contains(err.Error(), "查询语句语法错误") {
+		t.Fatalf("错误为 %v", err)
+	}
+	warnings, err := FOFA.Check(`port="https"`)
+	if err != nil || len(warnings) != 1 || !strings.Contains(warnings[0], "port 的值应为数字") {
+		t.Fatalf("提示为 %v, err=%v", warnings, err)
+	}
+}
+
+func TestValidateHandler(t *testing.T) {
+	result, err := ValidateHandler(ZoomEye)(context.Background(), map[string]interface{}{"query": `app:"nginx"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := result.StructuredContent.(validateOutput)
+	if output.Valid || output.Error == nil || output.Error.Position != 1 {
+		t.Fatalf("旧语法应返回语法错误: %+v", output)
+	}
+
+	result, err = ValidateHandler(FOFA)(context.Background(), map[string]interface{}{"query": `port=80`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output := result.StructuredContent.(validateOutput); !output.Valid || output.Normalized != `port="80"` || len(output.Warnings) != 1 {
+		t.Fatalf("检查结果为 %+v", output)
+	}
+}
+
+func TestHandleTranslateDefaultDialect(t *testing.T) {
+	cases := []struct {
+		defaultFrom *Dialect
+		args        map[string]interface{}
+		want        string
+	}{
+		{FOFA, map[string]interface{}{"query": `header="nginx"`}, `http.header="nginx"`},
+		{ZoomEye, map[string]interface{}{"query": `http.header="nginx"`}, `header="nginx"`},
+		{ZoomEye, map[string]interface{}{"query": `header="nginx"`, "from": "fofa"}, `http.header="nginx"`},
+	}
+	for _, tc := range cases {
+		result, err := handleTranslate(tc.args, tc.defaultFrom)
+		if err != nil {
+			t.Errorf("%v: %v", tc.args, err)
+			continue
+		}
+		if got := result.StructuredContent.(translateOutput).Translated; got != tc.want {
+			t.Errorf("%v: 翻译为 %s，期望 %s", tc.args, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package dork
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 查询翻译结果
+type Translation struct {
+	From         string         `json:"from"`
+	To           string         `json:"to"`
+	Translated   string         `json:"translated"`
+	Complete     bool           `json:"complete"` // 所有条件都已翻译
+	Untranslated []Untranslated `json:"untranslated,omitempty"`
+	Notes        []string       `json:"notes,omitempty"`
+}
+
+// 无法翻译而被省略的条件
+type Untranslated struct {
+	Clause   string `json:"clause"`
+	Position int    `json:"position"`
+	Reason   string `json:"reason"`
+}
+
+// FOFA 与 ZoomEye 含义相同的查询字段，按 FOFA 字段、ZoomEye 字段排列
+var fieldPairs = [][2]string{
+	{"title", "title"},
+	{"header", "http.header"},
+	{"header_hash", "http.header_hash"},
+	{"server", "http.header.server"},
+	{"status_code", "http.header.status_code"},
+	{"body", "http.body"},
+	{"body_hash", "http.body_hash"},
+	{"banner", "banner"},
+	{"icon_hash", "iconhash"},
+	{"cert", "ssl"},
+	{"cert.subject.cn", "ssl.cert.subject.cn"},
+	{"cert.issuer.cn", "ssl.cert.issuer.cn"},
+	{"cert.sn", "ssl.cert.serial"},
+	{"jarm", "ssl.jarm"},
+	{"tls.ja3s", "ssl.ja3s"},
+	{"tls.version", "ssl.version"},
+	{"ip", "ip"},
+	{"port", "port"},
+	{"domain", "domain"},
+	{"host", "hostname"},
+	{"asn", "asn"},
+	{"org", "org"},
+	{"os", "os"},
+	{"country", "country"},
+	{"region", "subdivisions"},
+	{"city", "city"},
+	{"app", "app"},
+	{"product", "product"},
+	{"protocol", "service"},
+	{"base_protocol", "protocol"},
+	{"is_domain", "is_domain"},
+	{"is_ipv6", "is_ipv6"},
+	{"is_honeypot", "is_honeypot"},
+	{"after", "after"},
+	{"before", "before"},
+}
+
+// 按源方言索引的字段映射
+var fieldMaps = map[*Dialect]map[string]string{
+	FOFA:    {},
+	ZoomEye: {"cidr": "ip"},
+}
+
+func init() {
+	for _, pair := range fieldPairs {
+		fieldMaps[FOFA][pair[0]] = pair[1]
+		fieldMaps[ZoomEye][pair[1]] = pair[0]
+	}
+}
+
+// FOFA 的 icon_hash 为 mmh3 哈希（有符号整数），ZoomEye 的 iconhash 还支持 md5
+var mmh3Re = regexp.MustCompile(`^-?\d+$`)
+
+// 将查询语句从一种语法翻译为另一种语法。无法翻译的条件会被省略，
+// 并在结果的 Untranslated 中说明；没有任何条件可以翻译时返回错误
+func Translate(query string, from, to *Dialect) (*Translation, error) {
+	if from == to {
+		return nil, fmt.Errorf("源语法和目标语法相同: %s", from.Name)
+	}
+	expr, err := from.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &translator{from: from, to: to, fields: fieldMaps[from], notes: map[string]bool{}}
+	out := t.translate(expr)
+	if out == nil {
+		reasons := make([]string, len(t.untranslated))
+		for i, u := range t.untranslated {
+			reasons[i] = u.Reason
+		}
+		return nil, fmt.Errorf("查询语句中没有可翻译为 %s 的条件: %s", to.Name, strings.Join(reasons, "; "))
+	}
+
+	return &Translation{
+		From:         from.Name,
+		To:           to.Name,
+		Translated:   out.String(),
+		Complete:     len(t.untranslated) == 0,
+		Untranslated: t.untranslated,
+		Notes:        t.noteList,
+	}, nil
+}
+
+type translator struct {
+	from, to     *Dialect
+	fields       map[string]string
+	untranslated []Untranslated
+	notes        map[string]bool
+	noteList     []string
+}
+
+func (t *translator) note(msg string) {
+	if !t.notes[msg] {
+		t.notes[msg] = true
+		t.noteList = append(t.noteList, msg)
+	}
+}
+
+// 翻译子表达式，全部条件都无法翻译时返回 nil
+func (t *translator) translate(expr Expr) Expr {
+	switch e := expr.(type) {
+	case *AndExpr:
+		terms := t.translateTerms(e.Terms, "省略 && 中的条件会使翻译后的查询匹配更多结果")
+		if len(terms) == 0 {
+			return nil
+		}
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return &AndExpr{Terms: terms}
+	case *OrExpr:
+		terms := t.translateTerms(e.Terms, "省略 || 中的条件会使翻译后的查询匹配更少结果")
+		if len(terms) == 0 {
+			return nil
+		}
+		if len(terms) == 1 {
+			return terms[0]
+		}
+		return &OrExpr{Terms: terms}
+	case *Condition:
+		c, reason := t.translateCondition(e)
+		if c == nil {
+			t.untranslated = append(t.untranslated, Untranslated{Clause: e.String(), Position: e.Pos, Reason: reason})
+			return nil
+		}
+		return c
+	}
+	return nil
+}
+
+// 翻译逻辑运算的各项。部分项被省略时添加说明 omitted；整个子表达式都被省略时
+// 不添加说明，由外层的逻辑运算按省略整个子表达式的效果说明
+func (t *translator) translateTerms(terms []Expr, omitted string) []Expr {
+	var out []Expr
+	for _, term := range terms {
+		if translated := t.translate(term); translated != nil {
+			out = append(out, translated)
+		}
+	}
+	if len(out) > 0 && len(out) < len(terms) {
+		t.note(omitted)
+	}
+	return out
+}
+
+func (t *translator) translateCondition(c *Condition) (*Condition, string) {
+	out := *c
+	if c.Key == "" {
+		return &out, ""
+	}
+
+	key, ok := t.fields[c.Key]
+	if !ok {
+		return nil, fmt.Sprintf("%s 没有与 %s 字段 %s 对应的查询字段", t.to.Name, t.from.Name, c.Key)
+	}
+	out.Key = key
+
+	switch {
+	case t.from == FOFA && c.Key == "ip" && strings.Contains(c.Value, "/"):
+		out.Key = "cidr"
+	case t.from == ZoomEye && c.Key == "iconhash" && !mmh3Re.MatchString(c.Value):
+		return nil, "FOFA 的 icon_hash 只支持 mmh3 哈希，无法翻译 md5 等其他格式的图标哈希"
+	}
+
+	if !t.to.ops[c.Op] {
+		// 目前只有 FOFA 的 *= 会走到这里，ZoomEye 的 = 本身就是模糊匹配
+		out.Op = "="
+		t.note(fmt.Sprintf("%s 不支持 %s，已改为 =（模糊匹配）", t.to.Name, c.Op))
+	}
+	return &out, ""
+}
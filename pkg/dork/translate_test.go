@@ -0,0 +1,105 @@
+package dork
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	cases := []struct {
+		from, to *Dialect
+		query    string
+		want     string
+	}{
+		{FOFA, ZoomEye, `title="login" && header="nginx" && body="admin" && port="443"`,
+			`title="login" && http.header="nginx" && http.body="admin" && port="443"`},
+		{FOFA, ZoomEye, `(country="CN" || region="Zhejiang") && asn="4134" && icon_hash="-247388890"`,
+			`(country="CN" || subdivisions="Zhejiang") && asn="4134" && iconhash="-247388890"`},
+		{FOFA, ZoomEye, `cert.subject.cn="example.com" && ip="10.0.0.0/8" && server!="IIS"`,
+			`ssl.cert.subject.cn="example.com" && cidr="10.0.0.0/8" && http.header.server!="IIS"`},
+		{FOFA, ZoomEye, `protocol=="https" && "login"`, `service=="https" && "login"`},
+		{ZoomEye, FOFA, `http.header.status_code="200" && ssl.jarm="abc" && hostname="a.com"`,
+			`status_code="200" && jarm="abc" && host="a.com"`},
+		{ZoomEye, FOFA, `cidr="1.2.3.0/24" && iconhash="116323821"`, `ip="1.2.3.0/24" && icon_hash="116323821"`},
+	}
+	for _, tc := range cases {
+		result, err := Translate(tc.query, tc.from, tc.to)
+		if err != nil {
+			t.Errorf("%s: %v", tc.query, err)
+			continue
+		}
+		if result.Translated != tc.want || !result.Complete {
+			t.Errorf("%s: 翻译为 %s（complete=%v），期望 %s", tc.query, result.Translated, result.Complete, tc.want)
+		}
+	}
+}
+
+func TestTranslateUntranslatable(t *testing.T) {
+	result, err := Translate(`app="nginx" && icp="京ICP备" && (title="a" || js_name="x.js")`, FOFA, ZoomEye)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Translated != `app="nginx" && title="a"` || result.Complete {
+		t.Fatalf("翻译结果为 %+v", result)
+	}
+	if len(result.Untranslated) != 2 || result.Untranslated[0].Clause != `icp="京ICP备"` || result.Untranslated[0].Position != 16 ||
+		result.Untranslated[1].Clause != `js_name="x.js"` {
+		t.Fatalf("未翻译的条件为 %+v", result.Untranslated)
+	}
+	notes := strings.Join(result.Notes, "\n")
+	if !strings.Contains(notes, "匹配更多结果") || !strings.Contains(notes, "匹配更少结果") {
+		t.Errorf("缺少说明:\n%s", notes)
+	}
+
+	// 省略整个子表达式时按外层的逻辑运算说明
+	nested := []struct{ query, want, note string }{
+		{`(icp="a" && js_name="b") || title="c"`, `title="c"`, "匹配更少结果"},
+		{`(icp="a" || js_name="b") && title="c"`, `title="c"`, "匹配更多结果"},
+	}
+	for _, tc := range nested {
+		result, err := Translate(tc.query, FOFA, ZoomEye)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Translated != tc.want || len(result.Notes) != 1 || !strings.Contains(result.Notes[0], tc.note) {
+			t.Errorf("%s: 翻译结果为 %+v", tc.query, result)
+		}
+	}
+
+	result, err = Translate(`iconhash="f3418a443e7d841097c714d69ec4bcb8" && port=80`, ZoomEye, FOFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Translated != `port="80"` || !strings.Contains(result.Untranslated[0].Reason, "mmh3") {
+		t.Fatalf("翻译结果为 %+v", result)
+	}
+
+	if _, err := Translate(`icp="京ICP备"`, FOFA, ZoomEye); err == nil || !strings.Contains(err.Error(), "没有可翻译") {
+		t.Fatalf("期望没有可翻译条件的错误，得到 %v", err)
+	}
+}
+
+func TestTranslateFuzzyOperator(t *testing.T) {
+	result, err := Translate(`host*="*.gov.cn"`, FOFA, ZoomEye)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Translated != `hostname="*.gov.cn"` || len(result.Notes) != 1 || !strings.Contains(result.Notes[0], "*=") {
+		t.Fatalf("翻译结果为 %+v", result)
+	}
+}
+
+func TestTranslateRoundTrip(t *testing.T) {
+	query := `title="login" && (port="80" || port="443") && cert.issuer.cn="Let's Encrypt" && country!="US"`
+	forward, err := Translate(query, FOFA, ZoomEye)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := Translate(forward.Translated, ZoomEye, FOFA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Translated != query {
+		t.Fatalf("往返翻译为 %s，期望 %s", back.Translated, query)
+	}
+}
@@ -0,0 +1,34 @@
+package dork
+
+import "strings"
+
+// ZoomEye 查询语法，支持 =、==、!=；旧版的 key:value 和 +/- 语法会给出提示
+var ZoomEye = newDialect(Dialect{
+	Name:        "ZoomEye",
+	ops:         map[string]bool{"=": true, "==": true, "!=": true},
+	opHints:     map[string]string{"*=": "模糊匹配请使用 ="},
+	numericKeys: map[string]bool{"port": true, "asn": true, "http.header.status_code": true, "ssl.chain_count": true, "ssl.cipher.bits": true},
+	booleanKeys: map[string]bool{"is_ipv4": true, "is_ipv6": true, "is_domain": true, "is_honeypot": true},
+	dateKeys:    map[string]bool{"after": true, "before": true},
+	legacyHint:  zoomEyeLegacyHint,
+}, []string{
+	"country", "subdivisions", "city",
+	"ip", "cidr", "org", "isp", "asn", "port", "hostname", "domain", "is_ipv4", "is_ipv6", "is_domain", "dig",
+	"ssl", "ssl.cert.fingerprint", "ssl.chain_count", "ssl.cert.alg", "ssl.cert.issuer.cn", "ssl.cert.subject.cn",
+	"ssl.cert.pubkey.type", "ssl.cert.serial", "ssl.cipher.bits", "ssl.cipher.name", "ssl.cipher.version",
+	"ssl.version", "ssl.jarm", "ssl.ja3s",
+	"app", "service", "device", "os", "industry", "product", "protocol", "is_honeypot", "title", "banner",
+	"http.header", "http.header_hash", "http.header.server", "http.header.version", "http.header.status_code",
+	"http.body", "http.body_hash", "iconhash", "filehash", "after", "before",
+})
+
+// 旧版语法：app:"nginx" +country:"CN" -port:80
+func zoomEyeLegacyHint(word string) string {
+	if strings.HasPrefix(word, "+") || strings.HasPrefix(word, "-") {
+		return "ZoomEye 已不支持用 +/- 连接条件的旧语法，请使用 && 和 !="
+	}
+	if strings.Contains(word, ":") {
+		return `ZoomEye 已不支持 key:value 的旧语法，请使用 key="value"`
+	}
+	return ""
+}
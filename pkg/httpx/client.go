@@ -0,0 +1,50 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTP 客户端配置
+type Options struct {
+	// 请求超时，包含重试和限速等待的时间
+	Timeout time.Duration
+	// 代理地址，为空时使用 HTTP_PROXY / HTTPS_PROXY 环境变量
+	Proxy string
+	// 网络错误、429 和 502/503/504 响应的重试策略，零值表示不重试
+	Retry RetryPolicy
+	// 每秒请求数上限，0 表示不限速
+	RateLimit float64
+	// 令牌桶容量，即允许的突发请求数，默认为 1
+	RateBurst int
+	// 返回请求的限速键，每个键使用独立的令牌桶；为 nil 时所有请求共享一个令牌桶
+	RateKey func(*http.Request) string
+}
+
+// 按配置创建 HTTP 客户端
+func NewClient(opts Options) (*http.Client, error) {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("代理地址无效: %w", err)
+		}
+		base.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	var rt http.RoundTripper = base
+	if opts.Retry.MaxRetries > 0 || opts.RateLimit > 0 {
+		t := &transport{base: base, retry: opts.Retry, rateKey: opts.RateKey}
+		if opts.RateLimit > 0 {
+			t.limiter = NewRateLimiter(opts.RateLimit, opts.RateBurst)
+		}
+		rt = t
+	}
+
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: rt,
+	}, nil
+}
@@ -0,0 +1,192 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var testRetry = RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 50 * time.Millisecond}
+
+func TestRetry(t *testing.T) {
+	cases := []struct {
+		name     string
+		statuses []int
+		retry    RetryPolicy
+		status   int
+		requests int32
+	}{
+		{"服务端暂时不可用后成功", []int{503, 502, 200}, testRetry, 200, 3},
+		{"重试次数用尽", []int{429, 429, 429, 429, 429}, testRetry, 429, 4},
+		{"认证失败不重试", []int{401, 200}, testRetry, 401, 1},
+		{"未配置重试", []int{503, 200}, RetryPolicy{}, 503, 1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&requests, 1)
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != "payload" {
+					t.Errorf("第 %d 次请求的请求体为 %q", n, body)
+				}
+				w.WriteHeader(tc.statuses[n-1])
+			}))
+			defer ts.Close()
+
+			client, err := NewClient(Options{Retry: tc.retry})
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Post(ts.URL, "text/plain", strings.NewReader("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status || requests != tc.requests {
+				t.Errorf("状态码 %d、请求 %d 次，期望 %d、%d 次", resp.StatusCode, requests, tc.status, tc.requests)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var requests int32
+	var first time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if wait := time.Since(first); wait < time.Second {
+			t.Errorf("只等待了 %v，未遵守 Retry-After", wait)
+		}
+	}))
+	defer ts.Close()
+
+	client, _ := NewClient(Options{Retry: RetryPolicy{MaxRetries: 1, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Second}})
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("状态码为 %d", resp.StatusCode)
+	}
+
+	// Retry-After 超过 MaxDelay 时不再重试，直接返回 429
+	atomic.StoreInt32(&requests, 0)
+	client, _ = NewClient(Options{Retry: RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond, MaxDelay: 100 * time.Millisecond}})
+	resp, err = client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests || requests != 1 {
+		t.Fatalf("状态码 %d、请求 %d 次", resp.StatusCode, requests)
+	}
+	if apiErr := NewStatusError(resp, "限速"); apiErr.RetryAfter != time.Second || !errors.Is(apiErr, ErrRateLimit) {
+		t.Errorf("错误为 %+v", apiErr)
+	}
+}
+
+func TestRetryCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	client, _ := NewClient(Options{Retry: RetryPolicy{MaxRetries: 3, MaxDelay: time.Minute}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	start := time.Now()
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("期望超时错误，得到 %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("取消后仍等待了 %v", elapsed)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	limiter := NewRateLimiter(2, 2)
+	limiter.now = func() time.Time { return now }
+
+	var delays []time.Duration
+	for i := 0; i < 4; i++ {
+		delays = append(delays, limiter.reserve("a"))
+	}
+	want := []time.Duration{0, 0, 500 * time.Millisecond, time.Second}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("等待时间为 %v，期望 %v", delays, want)
+		}
+	}
+
+	// 不同的键使用独立的令牌桶
+	if d := limiter.reserve("b"); d != 0 {
+		t.Fatalf("键 b 需要等待 %v", d)
+	}
+
+	// 1.5 秒后补充 3 个令牌，抵消已预约的 2 个请求
+	now = now.Add(1500 * time.Millisecond)
+	if d := limiter.reserve("a"); d != 0 {
+		t.Fatalf("补充令牌后仍需等待 %v", d)
+	}
+}
+
+func TestRateLimitPerKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	client, _ := NewClient(Options{
+		RateLimit: 10,
+		RateKey:   func(r *http.Request) string { return r.URL.Query().Get("key") },
+	})
+	start := time.Now()
+	for _, key := range []string{"a", "b", "a"} {
+		resp, err := client.Get(ts.URL + "?key=" + key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	// 只有第二个 a 需要等待约 100ms
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("耗时 %v", elapsed)
+	}
+}
+
+func TestClassifyMessage(t *testing.T) {
+	cases := map[string]ErrorKind{
+		"[-700] Account Invalid":            KindAuth,
+		"[820031] F点余额不足":                   KindQuota,
+		"您的每日查询次数已达上限":                      KindQuota,
+		"credits insufficient":              KindQuota,
+		"Too many requests, slow down":      KindRateLimit,
+		"[820000] query syntax error":       KindBadQuery,
+		"something unexpected happened":     KindUnknown,
+		"request frequency is too frequent": KindRateLimit,
+	}
+	for msg, want := range cases {
+		if got := ClassifyMessage(msg); got != want {
+			t.Errorf("%s: 判断为 %s，期望 %s", msg, got, want)
+		}
+	}
+
+	err := NewAPIError(KindQuota, "FOFA API错误: F点余额不足")
+	if !errors.Is(err, ErrQuota) || errors.Is(err, ErrAuth) || err.Error() != "FOFA API错误: F点余额不足 [quota_exceeded]" {
+		t.Errorf("错误为 %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API 错误类型
+type ErrorKind string
+
+const (
+	KindQuota     ErrorKind = "quota_exceeded" // 额度或积分不足
+	KindAuth      ErrorKind = "auth_failed"    // 凭证无效或没有权限
+	KindRateLimit ErrorKind = "rate_limited"   // 请求过于频繁
+	KindBadQuery  ErrorKind = "bad_query"      // 查询语句或参数错误
+	KindServer    ErrorKind = "server_error"   // 服务端暂时不可用
+	KindUnknown   ErrorKind = "unknown"
+)
+
+// 用于 errors.Is 判断错误类型，例如 errors.Is(err, httpx.ErrQuota)
+var (
+	ErrQuota     = &APIError{Kind: KindQuota}
+	ErrAuth      = &APIError{Kind: KindAuth}
+	ErrRateLimit = &APIError{Kind: KindRateLimit}
+	ErrBadQuery  = &APIError{Kind: KindBadQuery}
+	ErrServer    = &APIError{Kind: KindServer}
+)
+
+// 搜索引擎 API 返回的错误，Kind 区分额度不足、认证失败、限速和查询错误
+type APIError struct {
+	Kind       ErrorKind
+	StatusCode int           // HTTP 状态码，API 在 200 响应中报告的错误为 0
+	Message    string        // 原始错误信息
+	RetryAfter time.Duration // 服务端要求的等待时间，未指定时为 0
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s [%s]", e.Message, e.Kind)
+	if e.RetryAfter > 0 {
+		msg += fmt.Sprintf("，请在 %d 秒后重试", int(e.RetryAfter.Round(time.Second)/time.Second))
+	}
+	return msg
+}
+
+// 类型相同即视为匹配
+func (e *APIError) Is(target error) bool {
+	t, ok := target.(*APIError)
+	return ok && t.Kind == e.Kind
+}
+
+// 创建 API 错误，msg 为展示给调用方的完整信息
+func NewAPIError(kind ErrorKind, msg string) *APIError {
+	return &APIError{Kind: kind, Message: msg}
+}
+
+// 根据 HTTP 状态码创建错误，429 和 503 响应会带上 Retry-After 指定的等待时间
+func NewStatusError(resp *http.Response, msg string) *APIError {
+	return &APIError{
+		Kind:       StatusKind(resp.StatusCode),
+		StatusCode: resp.StatusCode,
+		Message:    msg,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// HTTP 状态码对应的错误类型
+func StatusKind(code int) ErrorKind {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return KindAuth
+	case code == http.StatusPaymentRequired:
+		return KindQuota
+	case code == http.StatusTooManyRequests:
+		return KindRateLimit
+	case code == http.StatusBadRequest || code == http.StatusUnprocessableEntity:
+		return KindBadQuery
+	case code >= 500:
+		return KindServer
+	}
+	return KindUnknown
+}
+
+// 各类错误信息中的关键词，按顺序匹配，额度不足优先于限速，
+// 避免"每日查询次数已达上限"被误判为限速
+var messageKeywords = []struct {
+	kind     ErrorKind
+	keywords []string
+}{
+	{KindAuth, []string{"account invalid", "api key", "apikey", "unauthorized", "forbidden", "认证", "未登录", "账号无效", "无权限", "没有权限"}},
+	{KindQuota, []string{"quota", "credit", "insufficient", "余额不足", "积分不足", "f点", "已达上限", "次数不足", "额度"}},
+	{KindRateLimit, []string{"rate limit", "too many", "too frequent", "频繁", "频率"}},
+	{KindBadQuery, []string{"query", "syntax", "param", "语法", "参数", "查询语句"}},
+}
+
+// 根据 API 返回的错误信息判断错误类型，用于状态码为 200 但响应中报告错误的接口
+func ClassifyMessage(msg string) ErrorKind {
+	lower := strings.ToLower(msg)
+	for _, entry := range messageKeywords {
+		for _, keyword := range entry.keywords {
+			if strings.Contains(lower, keyword) {
+				return entry.kind
+			}
+		}
+	}
+	return KindUnknown
+}
+
+// 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package httpx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// 按键（通常为 API Key）分别限速的令牌桶，每个键每秒补充 rate 个令牌，最多积累 burst 个
+type RateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// 创建限速器，rate 为每秒请求数，burst 小于 1 时按 1 处理
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// 等待 key 对应的令牌桶中有可用令牌，ctx 取消时返回错误
+func (l *RateLimiter) Wait(ctx context.Context, key string) error {
+	delay := l.reserve(key)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// 未使用的令牌归还，避免取消的请求占用后续请求的配额
+		l.mu.Lock()
+		l.buckets[key].tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// 预占一个令牌，返回需要等待的时间；令牌不足时令牌数为负，表示已预约的请求
+func (l *RateLimiter) reserve(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / l.rate * float64(time.Second))
+}
@@ -0,0 +1,124 @@
+package httpx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// 重试策略，MaxRetries 为 0 时不重试
+type RetryPolicy struct {
+	MaxRetries int           // 首次请求失败后最多重试的次数
+	BaseDelay  time.Duration // 首次重试的基准等待时间，之后每次翻倍
+	MaxDelay   time.Duration // 单次等待的上限，Retry-After 超过上限时不再重试
+}
+
+// 默认重试策略
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	BaseDelay:  500 * time.Millisecond,
+	MaxDelay:   10 * time.Second,
+}
+
+// 可重试的状态码：限速和服务端暂时不可用
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// 第 attempt 次重试（从0开始）前的等待时间：指数退避加全抖动，
+// 服务端指定了 Retry-After 时以其为准
+func (p RetryPolicy) delay(attempt int, retryAfter time.Duration) (time.Duration, bool) {
+	if retryAfter > 0 {
+		return retryAfter, retryAfter <= p.MaxDelay
+	}
+	backoff := p.MaxDelay
+	if attempt < 30 && p.BaseDelay<<attempt < p.MaxDelay {
+		backoff = p.BaseDelay << attempt
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+	return time.Duration(rand.Int63n(int64(backoff) + 1)), true
+}
+
+// 在底层 Transport 之上实现限速和重试
+type transport struct {
+	base    http.RoundTripper
+	retry   RetryPolicy
+	limiter *RateLimiter
+	rateKey func(*http.Request) string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	for attempt := 0; ; attempt++ {
+		if t.limiter != nil {
+			key := ""
+			if t.rateKey != nil {
+				key = t.rateKey(req)
+			}
+			if err := t.limiter.Wait(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+
+		attemptReq := req
+		if attempt > 0 && req.Body != nil {
+			// 重试时需要重新读取请求体，无法重新读取时直接返回
+			if req.GetBody == nil {
+				return nil, errors.New("请求体无法重新读取，不能重试")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(ctx)
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.retry.MaxRetries || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		var retryAfter time.Duration
+		if resp != nil {
+			retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		wait, ok := t.retry.delay(attempt, retryAfter)
+		if !ok {
+			return resp, err
+		}
+		if resp != nil {
+			// 读完并关闭响应体，以便复用连接
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// 网络错误和限速、网关类状态码可以重试，请求被取消时不重试
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		return true
+	}
+	return retryableStatus(resp.StatusCode)
+}
@@ -0,0 +1,527 @@
+// Package keypool 管理同一搜索引擎的多个 API Key。
+//
+// 每次请求从池中选择一个 key：轮流使用，或优先使用剩余额度最多的 key。
+// 遇到认证失败或额度不足的错误时暂停该 key 一段时间，并换用下一个 key 重试。
+// 结果中只展示 key 的名称，不展示密钥；池中的密钥会登记到 redact.Default，
+// 从错误、日志和工具结果中替换。
+package keypool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"securitymcp-hub/pkg/httpx"
+	"securitymcp-hub/pkg/redact"
+)
+
+// 一个 API 凭证
+type Key struct {
+	Label  string // 在结果和日志中展示的名称，不包含密钥
+	User   string // 账号，FOFA 为邮箱，ZoomEye 为空
+	Secret string // API Key
+}
+
+// 选择 key 的策略
+type Strategy string
+
+const (
+	RoundRobin Strategy = "round_robin" // 轮流使用
+	MostQuota  Strategy = "quota"       // 优先使用剩余额度最多的 key
+)
+
+// 解析选择策略，空字符串为轮流使用
+func ParseStrategy(s string) (Strategy, error) {
+	switch strategy := Strategy(s); strategy {
+	case "":
+		return RoundRobin, nil
+	case RoundRobin, MostQuota:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("未知的 API Key 选择策略 %q，可选值：round_robin、quota", s)
+}
+
+// 查询 key 的剩余额度
+type QuotaFunc func(ctx context.Context, key Key) (int, error)
+
+// 默认的暂停时长和剩余额度缓存时间
+const (
+	DefaultCooldown = time.Hour
+	DefaultQuotaTTL = 10 * time.Minute
+)
+
+// 池的选项
+type Options struct {
+	Strategy Strategy
+	Cooldown time.Duration // 认证失败或额度不足的 key 暂停使用的时长，默认 1 小时
+	Quota    QuotaFunc     // 按剩余额度选择时用于查询额度
+	QuotaTTL time.Duration // 剩余额度的缓存时间，默认 10 分钟
+}
+
+// 所有 key 都已暂停使用
+var ErrUnavailable = errors.New("所有 API Key 均已暂停使用")
+
+// API Key 池
+type Pool struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries []*entry
+	next    int // 轮流使用时下一个 key 的位置
+}
+
+type entry struct {
+	key          Key
+	calls        int
+	failures     int
+	benchedUntil time.Time
+	benchReason  string
+	quota        int
+	quotaKnown   bool
+	quotaAt      time.Time
+}
+
+// 创建 key 池，未设置名称的 key 按顺序命名为 key-1、key-2 ...
+func New(keys []Key, opts Options) (*Pool, error) {
+	entries, err := newEntries(keys)
+	if err != nil {
+		return nil, err
+	}
+	p := newPool(opts)
+	p.entries = entries
+	return p, nil
+}
+
+// 校验 key 并补全名称
+func newEntries(keys []Key) ([]*entry, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("没有可用的 API Key")
+	}
+	labels := make(map[string]bool)
+	entries := make([]*entry, 0, len(keys))
+	for i, key := range keys {
+		if key.Label == "" {
+			key.Label = fmt.Sprintf("key-%d", i+1)
+		}
+		if key.Secret == "" {
+			return nil, fmt.Errorf("API Key %s 的密钥为空", key.Label)
+		}
+		if labels[key.Label] {
+			return nil, fmt.Errorf("API Key 名称 %s 重复", key.Label)
+		}
+		labels[key.Label] = true
+		redact.Add(key.Secret)
+		entries = append(entries, &entry{key: key})
+	}
+	return entries, nil
+}
+
+// 替换池中的 key，用于重新加载凭证。凭证未变的 key 保留调用次数、暂停状态和已知额度；
+// 校验失败时保持原有的 key 不变
+func (p *Pool) Replace(keys []Key) error {
+	entries, err := newEntries(keys)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old := make(map[Key]*entry, len(p.entries))
+	for _, e := range p.entries {
+		old[e.key] = e
+	}
+	for i, e := range entries {
+		if prev, ok := old[e.key]; ok {
+			entries[i] = prev
+		}
+	}
+	p.entries = entries
+	p.next = 0
+	return nil
+}
+
+func newPool(opts Options) *Pool {
+	if opts.Strategy == "" {
+		opts.Strategy = RoundRobin
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = DefaultCooldown
+	}
+	if opts.QuotaTTL <= 0 {
+		opts.QuotaTTL = DefaultQuotaTTL
+	}
+	return &Pool{opts: opts, now: time.Now}
+}
+
+// 单个 key 组成的池，不校验密钥，密钥无效时由 API 返回认证错误
+func Single(key Key) *Pool {
+	if key.Label == "" {
+		key.Label = "key-1"
+	}
+	redact.Add(key.Secret)
+	p := newPool(Options{})
+	p.entries = []*entry{{key: key}}
+	return p
+}
+
+// 池中 key 的数量
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.entries)
+}
+
+// 第一个 key，用于只需要一个凭证的场景
+func (p *Pool) First() Key {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.entries[0].key
+}
+
+// 使用池中的 key 调用 fn。fn 返回认证失败或额度不足的错误时暂停该 key，
+// 并换用下一个未暂停的 key 重试；其他错误直接返回
+func (p *Pool) Do(ctx context.Context, fn func(Key) error) error {
+	usage := usageFrom(ctx)
+	tried := make(map[*entry]bool)
+	var lastErr error
+	for {
+		e := p.pick(ctx, tried)
+		if e == nil {
+			break
+		}
+		tried[e] = true
+		key := e.key
+
+		err := fn(key)
+		benched := p.record(e, err)
+		if !benched {
+			usage.served(key.Label)
+			return err
+		}
+		usage.failedOver(key.Label)
+		lastErr = err
+	}
+	if lastErr != nil {
+		return fmt.Errorf("所有 API Key 均不可用，最后一个错误: %w", lastErr)
+	}
+	return p.unavailable()
+}
+
+// 使用指定的 key 调用 fn，不换用其他 key。与 Do 一样记录调用结果，
+// 认证失败或额度不足时暂停该 key
+func (p *Pool) DoKey(ctx context.Context, key Key, fn func(Key) error) error {
+	p.mu.Lock()
+	var e *entry
+	for _, candidate := range p.entries {
+		if candidate.key == key {
+			e = candidate
+			break
+		}
+	}
+	p.mu.Unlock()
+	if e == nil {
+		return fmt.Errorf("API Key %s 不在池中", key.Label)
+	}
+
+	usage := usageFrom(ctx)
+	err := fn(key)
+	if p.record(e, err) {
+		usage.failedOver(key.Label)
+	} else if err == nil {
+		usage.served(key.Label)
+	}
+	return err
+}
+
+// 未暂停使用的 key
+func (p *Pool) Available() []Key {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	var keys []Key
+	for _, e := range p.entries {
+		if !now.Before(e.benchedUntil) {
+			keys = append(keys, e.key)
+		}
+	}
+	return keys
+}
+
+// 认证失败或额度不足时应暂停 key
+func benchReason(err error) string {
+	switch {
+	case errors.Is(err, httpx.ErrAuth):
+		return "认证失败"
+	case errors.Is(err, httpx.ErrQuota):
+		return "额度不足"
+	}
+	return ""
+}
+
+// 记录调用结果，需要暂停 key 时返回 true
+func (p *Pool) record(e *entry, err error) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e.calls++
+	if err == nil {
+		return false
+	}
+	e.failures++
+	reason := benchReason(err)
+	if reason == "" {
+		return false
+	}
+	e.benchedUntil = p.now().Add(p.opts.Cooldown)
+	e.benchReason = reason
+	return true
+}
+
+// 选择一个未暂停且本次未尝试过的 key，没有可用的 key 时返回 nil
+func (p *Pool) pick(ctx context.Context, tried map[*entry]bool) *entry {
+	if p.opts.Strategy == MostQuota && p.opts.Quota != nil {
+		p.refreshQuota(ctx, tried)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	available := func(e *entry) bool {
+		return !tried[e] && !now.Before(e.benchedUntil)
+	}
+
+	if p.opts.Strategy == MostQuota {
+		var best *entry
+		for _, e := range p.entries {
+			if !available(e) {
+				continue
+			}
+			// 额度未知的 key 排在额度已知的 key 之后
+			if best == nil || e.quotaKnown && (!best.quotaKnown || e.quota > best.quota) {
+				best = e
+			}
+		}
+		return best
+	}
+
+	for n := 0; n < len(p.entries); n++ {
+		i := (p.next + n) % len(p.entries)
+		if e := p.entries[i]; available(e) {
+			p.next = i + 1
+			return e
+		}
+	}
+	return nil
+}
+
+// 查询过期的剩余额度，查询时不持有锁
+func (p *Pool) refreshQuota(ctx context.Context, tried map[*entry]bool) {
+	p.mu.Lock()
+	now := p.now()
+	var stale []*entry
+	for _, e := range p.entries {
+		if !tried[e] && !now.Before(e.benchedUntil) && now.Sub(e.quotaAt) >= p.opts.QuotaTTL {
+			stale = append(stale, e)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, e := range stale {
+		quota, err := p.opts.Quota(ctx, e.key)
+		if err != nil && benchReason(err) != "" {
+			p.record(e, err)
+		}
+		p.mu.Lock()
+		e.quotaAt = p.now()
+		e.quota, e.quotaKnown = quota, err == nil
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pool) unavailable() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var details []string
+	for _, e := range p.entries {
+		details = append(details, fmt.Sprintf("%s（%s，%s 后恢复）", e.key.Label, e.benchReason, e.benchedUntil.Format("2006-01-02 15:04")))
+	}
+	return fmt.Errorf("%w：%s", ErrUnavailable, strings.Join(details, "、"))
+}
+
+// 单个 key 的状态，不包含密钥
+type KeyStatus struct {
+	Label        string     `json:"label"`
+	Available    bool       `json:"available"`
+	Calls        int        `json:"calls"`
+	Failures     int        `json:"failures"`
+	Quota        *int       `json:"quota,omitempty"`         // 最近一次查询到的剩余额度
+	BenchedUntil *time.Time `json:"benched_until,omitempty"` // 暂停使用到该时间
+	BenchReason  string     `json:"bench_reason,omitempty"`
+}
+
+// 所有 key 的状态
+func (p *Pool) Status() []KeyStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	statuses := make([]KeyStatus, 0, len(p.entries))
+	for _, e := range p.entries {
+		status := KeyStatus{
+			Label:     e.key.Label,
+			Available: !now.Before(e.benchedUntil),
+			Calls:     e.calls,
+			Failures:  e.failures,
+		}
+		if e.quotaKnown {
+			quota := e.quota
+			status.Quota = &quota
+		}
+		if !status.Available {
+			until := e.benchedUntil
+			status.BenchedUntil = &until
+			status.BenchReason = e.benchReason
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+type usageKey struct{}
+
+// 一次工具调用中使用的 key，同一调用中的多次请求（例如自动翻页）汇总在一起
+type Usage struct {
+	mu       sync.Mutex
+	servedBy []string
+	failed   []string
+}
+
+// 返回记录所用 key 的 context
+func Track(ctx context.Context) (context.Context, *Usage) {
+	usage := &Usage{}
+	return context.WithValue(ctx, usageKey{}, usage), usage
+}
+
+func usageFrom(ctx context.Context) *Usage {
+	usage, _ := ctx.Value(usageKey{}).(*Usage)
+	return usage
+}
+
+func appendUnique(list []string, label string) []string {
+	for _, l := range list {
+		if l == label {
+			return list
+		}
+	}
+	return append(list, label)
+}
+
+func (u *Usage) served(label string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.servedBy = appendUnique(u.servedBy, label)
+}
+
+func (u *Usage) failedOver(label string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.failed = appendUnique(u.failed, label)
+}
+
+// 工具结果中的 key 使用情况
+type Result struct {
+	ServedBy   []string `json:"served_by"`             // 返回结果的 key，命中缓存时为空
+	FailedOver []string `json:"failed_over,omitempty"` // 因认证失败或额度不足被暂停并换用其他 key 的 key
+}
+
+// 汇总 key 使用情况
+func (u *Usage) Result() *Result {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return &Result{
+		ServedBy:   append([]string{}, u.servedBy...),
+		FailedOver: append([]string(nil), u.failed...),
+	}
+}
+
+// 工具结果中 keys 的 JSON Schema
+var ResultSchema = map[string]interface{}{
+	"type":        "object",
+	"description": "本次调用使用的 API Key 名称（不包含密钥）",
+	"properties": map[string]interface{}{
+		"served_by":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "返回结果的 key，命中缓存时为空"},
+		"failed_over": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}, "description": "因认证失败或额度不足被暂停、换用其他 key 的 key"},
+	},
+}
+
+// 工具结果中 key 状态列表的 JSON Schema
+var StatusSchema = map[string]interface{}{
+	"type":        "array",
+	"description": "API Key 池中各 key 的状态（不包含密钥）",
+	"items": map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"label":         map[string]interface{}{"type": "string"},
+			"available":     map[string]interface{}{"type": "boolean"},
+			"calls":         map[string]interface{}{"type": "integer"},
+			"failures":      map[string]interface{}{"type": "integer"},
+			"quota":         map[string]interface{}{"type": "integer", "description": "最近一次查询到的剩余额度"},
+			"benched_until": map[string]interface{}{"type": "string", "format": "date-time"},
+			"bench_reason":  map[string]interface{}{"type": "string"},
+		},
+	},
+}
+
+// 解析多个 API Key。条目以逗号或换行分隔，格式为 [名称=]密钥；
+// withUser 为 true 时格式为 [名称=]账号:密钥。错误信息中不包含密钥
+func Parse(s string, withUser bool) ([]Key, error) {
+	var keys []Key
+	entries := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' })
+	for _, raw := range entries {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		var key Key
+		// 密钥中可能含有 =（例如 Base64 的填充），只有 = 前是合法的名称、= 后不为空且
+		// 不以 = 开头时才视为名称
+		if label, rest, ok := strings.Cut(raw, "="); ok && isLabel(strings.TrimSpace(label)) &&
+			rest != "" && !strings.HasPrefix(rest, "=") {
+			key.Label, raw = strings.TrimSpace(label), strings.TrimSpace(rest)
+		}
+		if withUser {
+			user, secret, ok := strings.Cut(raw, ":")
+			if !ok || user == "" || secret == "" {
+				return nil, fmt.Errorf("第 %d 个 API Key 格式错误，应为 [名称=]账号:密钥", len(keys)+1)
+			}
+			key.User, key.Secret = strings.TrimSpace(user), strings.TrimSpace(secret)
+		} else {
+			key.Secret = raw
+		}
+		if key.Secret == "" {
+			return nil, fmt.Errorf("第 %d 个 API Key 的密钥为空", len(keys)+1)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// 名称以字母开头，只包含字母、数字、下划线、连字符和点
+func isLabel(s string) bool {
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && (r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
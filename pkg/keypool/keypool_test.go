@@ -0,0 +1,177 @@
+package keypool
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"securitymcp-hub/pkg/httpx"
+)
+
+func TestParse(t *testing.T) {
+	keys, err := Parse("main=a@example.com:k1, b@example.com:k2=\n", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0] != (Key{Label: "main", User: "a@example.com", Secret: "k1"}) ||
+		keys[1] != (Key{User: "b@example.com", Secret: "k2="}) {
+		t.Fatalf("解析结果为 %+v", keys)
+	}
+
+	// Base64 密钥末尾的 = 不视为名称分隔符
+	keys, err = Parse("abc==,backup=def", false)
+	if err != nil || keys[0].Label != "" || keys[0].Secret != "abc==" || keys[1].Label != "backup" {
+		t.Fatalf("解析结果为 %+v, err=%v", keys, err)
+	}
+
+	// = 前不是合法名称时整项视为密钥
+	keys, err = Parse("a+b/c=d,9x=y,x y=z", false)
+	if err != nil || len(keys) != 3 || keys[0] != (Key{Secret: "a+b/c=d"}) || keys[1] != (Key{Secret: "9x=y"}) ||
+		keys[2] != (Key{Secret: "x y=z"}) {
+		t.Fatalf("解析结果为 %+v, err=%v", keys, err)
+	}
+	keys, err = Parse("user@example.com:a=b", true)
+	if err != nil || keys[0] != (Key{User: "user@example.com", Secret: "a=b"}) {
+		t.Fatalf("解析结果为 %+v, err=%v", keys, err)
+	}
+
+	_, err = Parse("a@example.com:secret1,secret2", true)
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Fatalf("错误为 %v", err)
+	}
+}
+
+func TestRoundRobinFailover(t *testing.T) {
+	pool, err := New([]Key{{Secret: "s1"}, {Secret: "s2"}, {Label: "backup", Secret: "s3"}}, Options{Cooldown: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	pool.now = func() time.Time { return now }
+
+	var used []string
+	call := func(fail map[string]error) error {
+		return pool.Do(context.Background(), func(key Key) error {
+			used = append(used, key.Label)
+			return fail[key.Label]
+		})
+	}
+
+	call(nil)
+	call(nil)
+	call(nil)
+	if strings.Join(used, ",") != "key-1,key-2,backup" {
+		t.Fatalf("使用顺序为 %v", used)
+	}
+
+	// 额度不足的 key 被暂停，换用下一个 key
+	used = nil
+	ctx, usage := Track(context.Background())
+	err = pool.Do(ctx, func(key Key) error {
+		used = append(used, key.Label)
+		if key.Label == "key-1" {
+			return httpx.NewAPIError(httpx.KindQuota, "F点余额不足")
+		}
+		return nil
+	})
+	if err != nil || strings.Join(used, ",") != "key-1,key-2" {
+		t.Fatalf("使用顺序为 %v, err=%v", used, err)
+	}
+	if result := usage.Result(); strings.Join(result.ServedBy, ",") != "key-2" || strings.Join(result.FailedOver, ",") != "key-1" {
+		t.Fatalf("key 使用情况为 %+v", result)
+	}
+	if status := pool.Status(); status[0].Available || status[0].BenchReason != "额度不足" {
+		t.Fatalf("key-1 状态为 %+v", status[0])
+	}
+
+	// 其他错误不换 key
+	used = nil
+	badQuery := httpx.NewAPIError(httpx.KindBadQuery, "query syntax error")
+	if err := call(map[string]error{"backup": badQuery}); !errors.Is(err, httpx.ErrBadQuery) || len(used) != 1 {
+		t.Fatalf("使用 %v, err=%v", used, err)
+	}
+
+	// 所有 key 都不可用
+	authErr := httpx.NewAPIError(httpx.KindAuth, "Account Invalid")
+	err = call(map[string]error{"key-2": authErr, "backup": authErr})
+	if !errors.Is(err, httpx.ErrAuth) {
+		t.Fatalf("错误为 %v", err)
+	}
+	if err := call(nil); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("错误为 %v", err)
+	}
+
+	// 暂停时间过后恢复使用
+	now = now.Add(time.Minute)
+	if err := call(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMostQuota(t *testing.T) {
+	quotas := map[string]int{"a": 10, "b": 50, "c": 30}
+	queried := 0
+	pool, err := New([]Key{{Label: "a", Secret: "1"}, {Label: "b", Secret: "2"}, {Label: "c", Secret: "3"}}, Options{
+		Strategy: MostQuota,
+		Quota: func(ctx context.Context, key Key) (int, error) {
+			queried++
+			return quotas[key.Label], nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var used []string
+	for i := 0; i < 2; i++ {
+		pool.Do(context.Background(), func(key Key) error {
+			used = append(used, key.Label)
+			return nil
+		})
+	}
+	if strings.Join(used, ",") != "b,b" || queried != 3 {
+		t.Fatalf("使用 %v，查询额度 %d 次", used, queried)
+	}
+
+	// 额度最多的 key 失败后换用额度次多的 key
+	used = nil
+	pool.Do(context.Background(), func(key Key) error {
+		used = append(used, key.Label)
+		if key.Label == "b" {
+			return httpx.NewAPIError(httpx.KindQuota, "余额不足")
+		}
+		return nil
+	})
+	if strings.Join(used, ",") != "b,c" {
+		t.Fatalf("使用 %v", used)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	pool, err := New([]Key{{Label: "a", Secret: "1"}, {Label: "b", Secret: "2"}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Do(context.Background(), func(key Key) error {
+		return httpx.NewAPIError(httpx.KindAuth, "Account Invalid")
+	})
+
+	// 校验失败时保留原有的 key
+	if err := pool.Replace(nil); err == nil || pool.Len() != 2 {
+		t.Fatalf("err=%v, Len=%d", err, pool.Len())
+	}
+
+	// a 的密钥已轮换，暂停状态随之清除；b 未变，保留调用次数
+	if err := pool.Replace([]Key{{Label: "a", Secret: "new"}, {Label: "b", Secret: "2"}}); err != nil {
+		t.Fatal(err)
+	}
+	status := pool.Status()
+	if !status[0].Available || status[0].Calls != 0 || status[1].Calls != 1 {
+		t.Fatalf("状态为 %+v", status)
+	}
+	if pool.First().Secret != "new" {
+		t.Fatalf("第一个 key 为 %+v", pool.First())
+	}
+}
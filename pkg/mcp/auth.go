@@ -0,0 +1,160 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// 调用方身份，Tools 为 nil 时允许调用全部工具
+type Caller struct {
+	Name  string
+	Tools map[string]bool
+}
+
+// 判断调用方是否可以调用指定工具，未认证的调用方（stdio 模式）不受限制
+func (c *Caller) CanCall(tool string) bool {
+	if c == nil || c.Tools == nil {
+		return true
+	}
+	return c.Tools[tool]
+}
+
+type callerKey struct{}
+
+// 将调用方身份写入 context
+func WithCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// 从 context 中取出调用方身份，没有时返回 nil
+func CallerFromContext(ctx context.Context) *Caller {
+	caller, _ := ctx.Value(callerKey{}).(*Caller)
+	return caller
+}
+
+// 静态 Bearer 令牌表，按令牌的 SHA-256 摘要索引，避免逐字节比较泄露时序信息
+type TokenStore struct {
+	callers map[[sha256.Size]byte]*Caller
+}
+
+// 从文件加载令牌，每行格式为：
+//
+//	名称 令牌 允许的工具
+//
+// 允许的工具以逗号分隔，* 表示全部工具；# 开头的行为注释。
+// 名称用于区分会话的归属，不能重复
+func LoadTokenFile(path string) (*TokenStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开令牌文件失败: %w", err)
+	}
+	defer file.Close()
+
+	store := &TokenStore{callers: make(map[[sha256.Size]byte]*Caller)}
+	names := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("令牌文件第 %d 行格式错误，应为：名称 令牌 允许的工具", lineNo)
+		}
+
+		if prev, exists := names[parts[0]]; exists {
+			return nil, fmt.Errorf("令牌文件第 %d 行的名称 %s 与第 %d 行重复", lineNo, parts[0], prev)
+		}
+		names[parts[0]] = lineNo
+
+		caller := &Caller{Name: parts[0]}
+		if parts[2] != "*" {
+			caller.Tools = make(map[string]bool)
+			for _, tool := range strings.Split(parts[2], ",") {
+				if tool = strings.TrimSpace(tool); tool != "" {
+					caller.Tools[tool] = true
+				}
+			}
+		}
+
+		digest := sha256.Sum256([]byte(parts[1]))
+		if _, exists := store.callers[digest]; exists {
+			return nil, fmt.Errorf("令牌文件第 %d 行的令牌与之前的行重复", lineNo)
+		}
+		store.callers[digest] = caller
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取令牌文件失败: %w", err)
+	}
+	if len(store.callers) == 0 {
+		return nil, fmt.Errorf("令牌文件 %s 中没有任何令牌", path)
+	}
+	return store, nil
+}
+
+// 根据 Authorization: Bearer 头查找调用方
+func (s *TokenStore) Authenticate(r *http.Request) (*Caller, bool) {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return nil, false
+	}
+	caller, ok := s.callers[sha256.Sum256([]byte(auth[len(prefix):]))]
+	return caller, ok
+}
+
+// 认证中间件：配置了令牌表时要求有效的 Bearer 令牌，
+// 否则在双向 TLS 下以客户端证书 CN 作为调用方身份。证书身份没有工具白名单，
+// 可调用全部工具，CN 相同的证书共用会话的归属
+func requireAuth(tokens *TokenStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var caller *Caller
+		if tokens != nil {
+			var ok bool
+			caller, ok = tokens.Authenticate(r)
+			if !ok {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mcp"`)
+				http.Error(w, "未授权", http.StatusUnauthorized)
+				return
+			}
+		} else if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+			caller = &Caller{Name: r.TLS.PeerCertificates[0].Subject.CommonName}
+		}
+
+		if caller != nil {
+			r = r.WithContext(WithCaller(r.Context(), caller))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 构建 TLS 配置，clientCA 非空时要求并校验客户端证书
+func tlsConfig(clientCA string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if clientCA == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(clientCA)
+	if err != nil {
+		return nil, fmt.Errorf("读取客户端 CA 失败: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("客户端 CA 文件 %s 中没有有效证书", clientCA)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newAuthTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "tokens")
+	content := "# 名称 令牌 允许的工具\nalice token-a echo\nops token-ops *\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := LoadTokenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(requireAuth(tokens, newTestServer().HTTPHandler()))
+}
+
+func postWithToken(t *testing.T, url, token, sessionID, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if sessionID != "" {
+		req.Header.Set(HeaderSessionID, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestBearerTokenAuth(t *testing.T) {
+	ts := newAuthTestServer(t)
+	defer ts.Close()
+
+	const initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`
+
+	for _, token := range []string{"", "wrong"} {
+		resp := postWithToken(t, ts.URL, token, "", initialize)
+		readBody(t, resp)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("令牌 %q 的状态码为 %d，期望 401", token, resp.StatusCode)
+		}
+	}
+
+	resp := postWithToken(t, ts.URL, "token-a", "", initialize)
+	readBody(t, resp)
+	sessionID := resp.Header.Get(HeaderSessionID)
+	if resp.StatusCode != http.StatusOK || sessionID == "" {
+		t.Fatalf("initialize 状态码 %d，会话 %q", resp.StatusCode, sessionID)
+	}
+
+	// 只能看到和调用允许的工具
+	resp = postWithToken(t, ts.URL, "token-a", sessionID, `{"jsonrpc":"2.0","id":2,"method":"tools/list"}`)
+	if body := readBody(t, resp); !jsonEqual(t, body, `{"jsonrpc":"2.0","id":2,"result":{"tools":[{"name":"echo","description":"回显参数","inputSchema":{"type":"object"}}]}}`) {
+		t.Fatalf("tools/list 响应不符: %s", body)
+	}
+
+	resp = postWithToken(t, ts.URL, "token-a", sessionID, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"fail","arguments":{}}}`)
+	if body := readBody(t, resp); !jsonEqual(t, body, `{"jsonrpc":"2.0","id":3,"error":{"code":-32001,"message":"Forbidden: 无权调用工具: fail"}}`) {
+		t.Fatalf("越权调用响应不符: %s", body)
+	}
+
+	// 其他调用方不能使用该会话
+	resp = postWithToken(t, ts.URL, "token-ops", sessionID, `{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("使用他人会话的状态码为 %d，期望 404", resp.StatusCode)
+	}
+}
+
+func TestLoadTokenFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]string{
+		"empty":     "# 只有注释\n",
+		"malformed": "alice token-a\n",
+		"duplicate": "alice token-a echo\nbob token-a *\n",
+		"same name": "alice token-a echo\nalice token-b *\n",
+	}
+	for name, content := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadTokenFile(path); err == nil {
+			t.Errorf("%s: 期望加载失败", name)
+		}
+	}
+}
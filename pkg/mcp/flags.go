@@ -0,0 +1,39 @@
+package mcp
+
+import (
+	"flag"
+	"time"
+)
+
+// 所有服务通用的命令行参数
+type Flags struct {
+	HTTP               HTTPOptions
+	MaxMessageSize     int
+	SessionIdleTimeout time.Duration
+	MaxSessions        int
+}
+
+// 在 fs 上注册通用命令行参数
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.StringVar(&f.HTTP.Addr, "http", "", "以 Streamable HTTP 模式监听的地址，例如 :8080；为空时使用 stdio")
+	fs.StringVar(&f.HTTP.TokenFile, "auth-tokens", "", "Bearer 令牌文件，每行：名称 令牌 允许的工具（逗号分隔，* 表示全部），名称不能重复")
+	fs.StringVar(&f.HTTP.TLSCert, "tls-cert", "", "HTTPS 服务端证书")
+	fs.StringVar(&f.HTTP.TLSKey, "tls-key", "", "HTTPS 服务端私钥")
+	fs.StringVar(&f.HTTP.ClientCA, "tls-client-ca", "", "客户端 CA 证书，设置后要求双向 TLS；未配置 -auth-tokens 时以证书 CN 作为调用方，可调用全部工具")
+	fs.IntVar(&f.MaxMessageSize, "max-message-size", DefaultMaxMessageSize, "单条 JSON-RPC 消息最大字节数")
+	fs.DurationVar(&f.SessionIdleTimeout, "session-idle-timeout", DefaultSessionIdleTimeout, "HTTP 会话空闲超过该时长后被回收")
+	fs.IntVar(&f.MaxSessions, "max-sessions", DefaultMaxSessions, "同时存在的 HTTP 会话数上限")
+	return f
+}
+
+// 按命令行参数运行服务：指定 -http 时提供 Streamable HTTP 服务，否则使用 stdio
+func (s *Server) Run(f *Flags) error {
+	s.MaxMessageSize = f.MaxMessageSize
+	s.SessionIdleTimeout = f.SessionIdleTimeout
+	s.MaxSessions = f.MaxSessions
+	if f.HTTP.Addr != "" {
+		return s.ListenAndServe(f.HTTP)
+	}
+	return s.ServeStdio()
+}
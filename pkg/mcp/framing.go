@@ -0,0 +1,89 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// 默认单条消息最大字节数
+const DefaultMaxMessageSize = 16 << 20
+
+// 消息超过最大长度，该消息已被完整丢弃，可以继续读取下一条
+var ErrMessageTooLarge = errors.New("消息超过最大长度")
+
+// 按换行分帧的消息读取器，与 bufio.Scanner 不同，
+// 超长消息不会中断读取，而是丢弃该行后返回 ErrMessageTooLarge
+type messageReader struct {
+	r   *bufio.Reader
+	max int
+}
+
+func newMessageReader(r io.Reader, max int) *messageReader {
+	if max <= 0 {
+		max = DefaultMaxMessageSize
+	}
+	return &messageReader{
+		r:   bufio.NewReader(r),
+		max: max,
+	}
+}
+
+// 读取下一条非空消息，不含行尾的 \r\n。输入结束时返回 io.EOF
+func (m *messageReader) ReadMessage() ([]byte, error) {
+	for {
+		line, err := m.readLine()
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r")
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		return line, nil
+	}
+}
+
+func (m *messageReader) readLine() ([]byte, error) {
+	var line []byte
+	tooLarge := false
+
+	for {
+		chunk, err := m.r.ReadSlice('\n')
+		if !tooLarge {
+			size := len(line) + len(chunk)
+			if err == nil {
+				size-- // 不计换行符
+			}
+			if size > m.max {
+				// 超长后只继续消费到行尾，不再保留内容
+				tooLarge = true
+				line = nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+
+		switch {
+		case err == nil:
+			if tooLarge {
+				return nil, ErrMessageTooLarge
+			}
+			return line[:len(line)-1], nil
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF:
+			// 最后一行可能没有换行符
+			if tooLarge {
+				return nil, ErrMessageTooLarge
+			}
+			if len(line) > 0 {
+				return line, nil
+			}
+			return nil, io.EOF
+		default:
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package mcp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Streamable HTTP 传输使用的会话头
+const HeaderSessionID = "Mcp-Session-Id"
+
+// 2025-06-18 起客户端在 initialize 之后的请求中携带协商的协议版本
+const HeaderProtocolVersion = "Mcp-Protocol-Version"
+
+// Streamable HTTP 端点路径
+const HTTPEndpoint = "/mcp"
+
+// SSE 流的保活间隔
+const sseKeepAlive = 30 * time.Second
+
+// 会话空闲超过该时长后被回收
+const DefaultSessionIdleTimeout = 30 * time.Minute
+
+// 同时存在的会话数上限，达到上限后拒绝新的 initialize
+const DefaultMaxSessions = 1000
+
+// Streamable HTTP 传输：POST 发送请求，GET 打开服务器消息的 SSE 流，
+// DELETE 结束会话。会话由 initialize 创建，通过 Mcp-Session-Id 头标识
+type httpTransport struct {
+	server *Server
+
+	mu       sync.Mutex
+	sessions map[string]*httpSession
+}
+
+// HTTP 会话，只能由创建它的调用方使用
+type httpSession struct {
+	*session
+	id        string
+	owner     string
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// 以下字段由 httpTransport.mu 保护
+	active   int       // 正在处理的请求和打开的 SSE 流
+	lastUsed time.Time // 最近一次请求结束的时间
+	idle     *time.Timer
+	removed  bool
+}
+
+func (s *httpSession) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.cancelAll()
+	})
+}
+
+// 返回实现 MCP Streamable HTTP 传输的 http.Handler，与 stdio 模式共用同一组工具
+func (s *Server) HTTPHandler() http.Handler {
+	return &httpTransport{
+		server:   s,
+		sessions: make(map[string]*httpSession),
+	}
+}
+
+// Streamable HTTP 服务配置
+type HTTPOptions struct {
+	Addr string
+
+	// 令牌文件路径，非空时要求 Authorization: Bearer 令牌，格式见 LoadTokenFile
+	TokenFile string
+
+	// 服务端证书和私钥，非空时以 HTTPS 提供服务
+	TLSCert string
+	TLSKey  string
+
+	// 客户端 CA 证书，非空时要求双向 TLS
+	ClientCA string
+}
+
+// 按 opts 提供 Streamable HTTP 服务，端点为 HTTPEndpoint
+func (s *Server) ListenAndServe(opts HTTPOptions) error {
+	var tokens *TokenStore
+	if opts.TokenFile != "" {
+		var err error
+		if tokens, err = LoadTokenFile(opts.TokenFile); err != nil {
+			return err
+		}
+	}
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return fmt.Errorf("TLS 证书和私钥必须同时配置")
+	}
+	if opts.ClientCA != "" && opts.TLSCert == "" {
+		return fmt.Errorf("双向 TLS 需要同时配置服务端证书和私钥")
+	}
+	if tokens == nil && opts.ClientCA == "" {
+		log.Printf("警告: %s 未启用认证，任何能访问 %s 的客户端都可以调用全部工具", s.Name, opts.Addr)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(HTTPEndpoint, requireAuth(tokens, s.HTTPHandler()))
+	httpServer := &http.Server{
+		Addr:    opts.Addr,
+		Handler: mux,
+	}
+
+	if opts.TLSCert == "" {
+		log.Printf("%s 监听 http://%s%s", s.Name, opts.Addr, HTTPEndpoint)
+		return httpServer.ListenAndServe()
+	}
+
+	config, err := tlsConfig(opts.ClientCA)
+	if err != nil {
+		return err
+	}
+	httpServer.TLSConfig = config
+	log.Printf("%s 监听 https://%s%s", s.Name, opts.Addr, HTTPEndpoint)
+	return httpServer.ListenAndServeTLS(opts.TLSCert, opts.TLSKey)
+}
+
+func (t *httpTransport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !validOrigin(r) {
+		http.Error(w, "Origin 不被允许", http.StatusForbidden)
+		return
+	}
+
+	if version := r.Header.Get(HeaderProtocolVersion); version != "" && !isSupportedProtocolVersion(version) {
+		http.Error(w, "不支持的协议版本: "+version, http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		t.handlePost(w, r)
+	case http.MethodGet:
+		t.handleGet(w, r)
+	case http.MethodDelete:
+		t.handleDelete(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) sessionIdleTimeout() time.Duration {
+	if s.SessionIdleTimeout > 0 {
+		return s.SessionIdleTimeout
+	}
+	return DefaultSessionIdleTimeout
+}
+
+func (s *Server) maxSessions() int {
+	if s.MaxSessions > 0 {
+		return s.MaxSessions
+	}
+	return DefaultMaxSessions
+}
+
+func (t *httpTransport) handlePost(w http.ResponseWriter, r *http.Request) {
+	max := t.server.MaxMessageSize
+	if max <= 0 {
+		max = DefaultMaxMessageSize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(max)))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errorResponse(json.RawMessage("null"), CodeInvalidRequest, "Invalid Request",
+				fmt.Sprintf("%v（上限 %d 字节）", ErrMessageTooLarge, max)))
+			return
+		}
+		http.Error(w, "读取请求失败", http.StatusBadRequest)
+		return
+	}
+
+	requests, batch, err := decodeMessages(body)
+	if errors.Is(err, errInvalidBatch) {
+		writeJSON(w, http.StatusBadRequest, errorResponse(json.RawMessage("null"), CodeInvalidRequest, "Invalid Request", err.Error()))
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse(json.RawMessage("null"), CodeParseError, "Parse error", err.Error()))
+		return
+	}
+
+	sess, ok := t.sessionFor(w, r, requests)
+	if !ok {
+		return
+	}
+	defer t.release(sess)
+
+	pending := 0
+	hasToolCall := false
+	for _, request := range requests {
+		if !request.IsNotification() {
+			pending++
+		}
+		if request.Method == "tools/call" {
+			hasToolCall = true
+		}
+	}
+
+	// 只有通知的 POST 直接返回 202
+	if pending == 0 {
+		for _, request := range requests {
+			sess.handle(r.Context(), request, nil)
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	responses := make(chan *Response, pending)
+	reply := func(response *Response) {
+		responses <- response
+	}
+	for _, request := range requests {
+		sess.handle(r.Context(), request, reply)
+	}
+
+	// 含工具调用且客户端接受 SSE 时以流的形式逐条返回，其余情况返回普通 JSON
+	if hasToolCall && acceptsEventStream(r) {
+		writeEventStream(w, responses, pending)
+		return
+	}
+
+	var collected []*Response
+	for i := 0; i < pending; i++ {
+		if response := <-responses; response != nil {
+			collected = append(collected, response)
+		}
+	}
+	switch {
+	case len(collected) == 0:
+		w.WriteHeader(http.StatusAccepted)
+	case batch:
+		writeJSON(w, http.StatusOK, collected)
+	default:
+		writeJSON(w, http.StatusOK, collected[0])
+	}
+}
+
+// 查找请求所属会话，initialize 请求创建新会话；返回的会话在请求结束后需要 release
+func (t *httpTransport) sessionFor(w http.ResponseWriter, r *http.Request, requests []*Request) (*httpSession, bool) {
+	if r.Header.Get(HeaderSessionID) != "" {
+		sess := t.lookup(r)
+		if sess == nil {
+			http.Error(w, "会话不存在或已结束", http.StatusNotFound)
+			return nil, false
+		}
+		return sess, true
+	}
+
+	for _, request := range requests {
+		if request.Method == "initialize" {
+			sess, err := t.newSession(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return nil, false
+			}
+			w.Header().Set(HeaderSessionID, sess.id)
+			return sess, true
+		}
+	}
+
+	http.Error(w, "缺少 "+HeaderSessionID+" 头", http.StatusBadRequest)
+	return nil, false
+}
+
+func (t *httpTransport) handleGet(w http.ResponseWriter, r *http.Request) {
+	if !acceptsEventStream(r) {
+		http.Error(w, "需要 Accept: text/event-stream", http.StatusNotAcceptable)
+		return
+	}
+
+	sess := t.lookup(r)
+	if sess == nil {
+		http.Error(w, "会话不存在或已结束", http.StatusNotFound)
+		return
+	}
+	defer t.release(sess)
+
+	// 目前服务器不会主动发送消息，流保持打开直到客户端断开或会话结束
+	flusher, ok := startEventStream(w)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-sess.done:
+			return
+		case <-ticker.C:
+			io.WriteString(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func (t *httpTransport) handleDelete(w http.ResponseWriter, r *http.Request) {
+	sess := t.lookup(r)
+	if sess == nil {
+		http.Error(w, "会话不存在或已结束", http.StatusNotFound)
+		return
+	}
+
+	t.mu.Lock()
+	t.removeLocked(sess)
+	t.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 创建会话，会话数达到上限时返回错误；返回的会话需要 release
+func (t *httpTransport) newSession(r *http.Request) (*httpSession, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if max := t.server.maxSessions(); len(t.sessions) >= max {
+		return nil, fmt.Errorf("会话数已达上限 %d，请结束不再使用的会话（DELETE）或稍后重试", max)
+	}
+
+	sess := &httpSession{
+		session: newSession(t.server),
+		id:      newSessionID(),
+		owner:   callerName(r),
+		done:    make(chan struct{}),
+		active:  1,
+	}
+	sess.idle = time.AfterFunc(t.server.sessionIdleTimeout(), func() { t.expire(sess) })
+	t.sessions[sess.id] = sess
+	return sess, nil
+}
+
+// 查找请求头中的会话，其他调用方创建的会话视为不存在；返回的会话需要 release
+func (t *httpTransport) lookup(r *http.Request) *httpSession {
+	id := r.Header.Get(HeaderSessionID)
+	if id == "" {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sess := t.sessions[id]
+	if sess == nil || sess.owner != callerName(r) {
+		return nil
+	}
+	sess.active++
+	return sess
+}
+
+// 请求或 SSE 流结束，会话从此刻起重新计算空闲时间
+func (t *httpTransport) release(sess *httpSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sess.active--
+	sess.lastUsed = time.Now()
+	if !sess.removed {
+		sess.idle.Reset(t.server.sessionIdleTimeout())
+	}
+}
+
+// 空闲计时器到期时回收会话，仍有请求在处理的会话不回收
+func (t *httpTransport) expire(sess *httpSession) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if sess.removed || sess.active > 0 {
+		return
+	}
+	if remaining := t.server.sessionIdleTimeout() - time.Since(sess.lastUsed); remaining > 0 {
+		sess.idle.Reset(remaining)
+		return
+	}
+	t.removeLocked(sess)
+}
+
+func (t *httpTransport) removeLocked(sess *httpSession) {
+	if sess.removed {
+		return
+	}
+	sess.removed = true
+	sess.idle.Stop()
+	delete(t.sessions, sess.id)
+	sess.close()
+}
+
+func callerName(r *http.Request) string {
+	if caller := CallerFromContext(r.Context()); caller != nil {
+		return caller.Name
+	}
+	return ""
+}
+
+// JSON 格式正确但不是合法请求的批量消息
+var errInvalidBatch = errors.New("无效的批量请求")
+
+// 解析单条消息或批量消息，批量消息为空或含有 null 时返回 errInvalidBatch
+func decodeMessages(body []byte) (requests []*Request, batch bool, err error) {
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, true, err
+		}
+		if len(requests) == 0 {
+			return nil, true, fmt.Errorf("%w: 批量请求为空", errInvalidBatch)
+		}
+		for i, request := range requests {
+			if request == nil {
+				return nil, true, fmt.Errorf("%w: 第 %d 条消息为 null", errInvalidBatch, i+1)
+			}
+		}
+		return requests, true, nil
+	}
+
+	var request Request
+	if err := json.Unmarshal(body, &request); err != nil {
+		return nil, false, err
+	}
+	return []*Request{&request}, false, nil
+}
+
+// 以 SSE 逐条写出 n 个响应，已取消的请求（nil）不写出
+func writeEventStream(w http.ResponseWriter, responses <-chan *Response, n int) {
+	flusher, ok := startEventStream(w)
+	for i := 0; i < n; i++ {
+		response := <-responses
+		if response == nil || !ok {
+			continue
+		}
+		data, err := json.Marshal(response)
+		if err != nil {
+			log.Printf("编码响应失败: %v", err)
+			continue
+		}
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+		flusher.Flush()
+	}
+}
+
+func startEventStream(w http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return nil, false
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return flusher, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("编码响应失败: %v", err)
+	}
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		if strings.Contains(accept, "text/event-stream") {
+			return true
+		}
+	}
+	return false
+}
+
+// 防止 DNS 重绑定：带 Origin 头的请求必须来自同一主机
+func validOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return u.Host == r.Host
+}
+
+func newSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Sprintf("mcp: 生成会话 ID 失败: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
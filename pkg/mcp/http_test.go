@@ -0,0 +1,179 @@
+package mcp
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func postMCP(t *testing.T, url, sessionID, accept, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+	if sessionID != "" {
+		req.Header.Set(HeaderSessionID, sessionID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestStreamableHTTPSession(t *testing.T) {
+	ts := httptest.NewServer(newTestServer().HTTPHandler())
+	defer ts.Close()
+
+	const accept = "application/json, text/event-stream"
+
+	// 没有会话且不是 initialize
+	resp := postMCP(t, ts.URL, "", accept, `{"jsonrpc":"2.0","id":1,"method":"ping"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("缺少会话头时状态码为 %d，期望 400", resp.StatusCode)
+	}
+
+	resp = postMCP(t, ts.URL, "", accept, `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	readBody(t, resp)
+	sessionID := resp.Header.Get(HeaderSessionID)
+	if resp.StatusCode != http.StatusOK || sessionID == "" {
+		t.Fatalf("initialize 状态码 %d，会话 %q", resp.StatusCode, sessionID)
+	}
+
+	// 通知返回 202 且没有响应体
+	resp = postMCP(t, ts.URL, sessionID, accept, `{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusAccepted || body != "" {
+		t.Fatalf("通知状态码 %d，响应体 %q", resp.StatusCode, body)
+	}
+
+	// 普通请求返回 JSON
+	resp = postMCP(t, ts.URL, sessionID, accept, `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	if body := readBody(t, resp); !jsonEqual(t, body, `{"jsonrpc":"2.0","id":2,"result":{}}`) {
+		t.Fatalf("ping 响应不符: %s", body)
+	}
+
+	// 工具调用以 SSE 返回
+	resp = postMCP(t, ts.URL, sessionID, accept, `{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"echo","arguments":{"text":"hi"}}}`)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("工具调用 Content-Type 为 %q，期望 text/event-stream", ct)
+	}
+	var data string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			data = strings.TrimPrefix(scanner.Text(), "data: ")
+		}
+	}
+	resp.Body.Close()
+	if !jsonEqual(t, data, `{"jsonrpc":"2.0","id":3,"result":{"content":[{"type":"text","text":"hi"}]}}`) {
+		t.Fatalf("SSE 工具调用响应不符: %s", data)
+	}
+
+	// 批量请求返回数组
+	resp = postMCP(t, ts.URL, sessionID, "application/json", `[{"jsonrpc":"2.0","id":4,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/initialized"}]`)
+	if body := readBody(t, resp); !jsonEqual(t, body, `[{"jsonrpc":"2.0","id":4,"result":{}}]`) {
+		t.Fatalf("批量响应不符: %s", body)
+	}
+
+	// 批量请求中的 null 返回 Invalid Request，不处理其余消息
+	for _, batch := range []string{`[null]`, `[{"jsonrpc":"2.0","id":5,"method":"ping"},null]`} {
+		resp = postMCP(t, ts.URL, sessionID, "application/json", batch)
+		if body := readBody(t, resp); resp.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"code":-32600`) {
+			t.Fatalf("%s: 状态码 %d，响应体 %s", batch, resp.StatusCode, body)
+		}
+	}
+
+	// 结束会话后会话不可再用
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(HeaderSessionID, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE 状态码为 %d，期望 204", resp.StatusCode)
+	}
+
+	resp = postMCP(t, ts.URL, sessionID, accept, `{"jsonrpc":"2.0","id":5,"method":"ping"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("已结束会话的状态码为 %d，期望 404", resp.StatusCode)
+	}
+}
+
+func TestStreamableHTTPSessionIdleExpiry(t *testing.T) {
+	server := newTestServer()
+	server.SessionIdleTimeout = 50 * time.Millisecond
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	resp := postMCP(t, ts.URL, "", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	readBody(t, resp)
+	sessionID := resp.Header.Get(HeaderSessionID)
+
+	// 空闲时间内的请求会重新计时
+	time.Sleep(30 * time.Millisecond)
+	resp = postMCP(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("空闲超时前的请求状态码为 %d，期望 200", resp.StatusCode)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	resp = postMCP(t, ts.URL, sessionID, "application/json", `{"jsonrpc":"2.0","id":3,"method":"ping"}`)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("空闲会话的状态码为 %d，期望 404", resp.StatusCode)
+	}
+}
+
+func TestStreamableHTTPMaxSessions(t *testing.T) {
+	server := newTestServer()
+	server.MaxSessions = 1
+	ts := httptest.NewServer(server.HTTPHandler())
+	defer ts.Close()
+
+	const initialize = `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`
+	resp := postMCP(t, ts.URL, "", "application/json", initialize)
+	readBody(t, resp)
+	sessionID := resp.Header.Get(HeaderSessionID)
+
+	resp = postMCP(t, ts.URL, "", "application/json", initialize)
+	if body := readBody(t, resp); resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(body, "会话数已达上限") {
+		t.Fatalf("超出会话上限时状态码 %d，响应体 %q", resp.StatusCode, body)
+	}
+
+	// 结束会话后可以创建新会话
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL, nil)
+	req.Header.Set(HeaderSessionID, sessionID)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	readBody(t, resp)
+	resp = postMCP(t, ts.URL, "", "application/json", initialize)
+	readBody(t, resp)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("结束会话后 initialize 状态码为 %d，期望 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,73 @@
+package mcp
+
+import "encoding/json"
+
+// JSON-RPC 标准错误码
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+
+	// 服务器自定义错误码：调用方无权调用该工具
+	CodeForbidden = -32001
+)
+
+// MCP请求结构，ID 保留原始 JSON 以区分缺失的 id 和 null
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// 没有 id 的消息是通知，服务器不得对其响应
+func (r *Request) IsNotification() bool {
+	return len(r.ID) == 0
+}
+
+// MCP响应结构
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// MCP错误结构
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// 工具定义
+type Tool struct {
+	Name         string                 `json:"name"`
+	Description  string                 `json:"description"`
+	InputSchema  map[string]interface{} `json:"inputSchema"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+	Annotations  *ToolAnnotations       `json:"annotations,omitempty"`
+}
+
+// 工具注解，均为提示信息，客户端不应据此做安全决策
+type ToolAnnotations struct {
+	Title           string `json:"title,omitempty"`
+	ReadOnlyHint    bool   `json:"readOnlyHint"`
+	DestructiveHint bool   `json:"destructiveHint"`
+	IdempotentHint  bool   `json:"idempotentHint"`
+	OpenWorldHint   bool   `json:"openWorldHint"`
+}
+
+// 调用工具请求
+type CallToolRequest struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// 调用工具结果，StructuredContent 为符合工具 outputSchema 的结构化结果
+type CallToolResult struct {
+	Content           []map[string]interface{} `json:"content"`
+	StructuredContent interface{}              `json:"structuredContent,omitempty"`
+	IsError           bool                     `json:"isError,omitempty"`
+}
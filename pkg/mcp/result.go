@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"securitymcp-hub/pkg/redact"
+)
+
+// 构建纯文本工具结果
+func TextResult(text string) CallToolResult {
+	return CallToolResult{
+		Content: []map[string]interface{}{
+			{
+				"type": "text",
+				"text": text,
+			},
+		},
+	}
+}
+
+// 将任意结构格式化为 JSON 文本工具结果
+func JSONResult(v interface{}) CallToolResult {
+	responseJSON, _ := json.MarshalIndent(v, "", "  ")
+	return TextResult(string(responseJSON))
+}
+
+// 构建结构化工具结果，同时保留格式化 JSON 文本以兼容只读取 content 的客户端
+func StructuredResult(v interface{}) CallToolResult {
+	result := JSONResult(v)
+	result.StructuredContent = v
+	return result
+}
+
+// 构建错误工具结果，错误信息中的凭证会被替换
+func ErrorResult(err error) CallToolResult {
+	result := TextResult(fmt.Sprintf("错误: %v", redact.Error(err)))
+	result.IsError = true
+	return result
+}
+
+// 替换工具结果中的凭证后再发送给客户端。错误结果按错误信息处理，
+// 其余结果只替换已登记的密钥
+func redactResult(result CallToolResult) CallToolResult {
+	clean := func(s string) string {
+		if result.IsError {
+			return redact.String(s)
+		}
+		return redact.Replace(s)
+	}
+	content := make([]map[string]interface{}, len(result.Content))
+	for i, item := range result.Content {
+		content[i] = item
+		if text, ok := item["text"].(string); ok {
+			if cleaned := clean(text); cleaned != text {
+				content[i] = make(map[string]interface{}, len(item))
+				for k, v := range item {
+					content[i][k] = v
+				}
+				content[i]["text"] = cleaned
+			}
+		}
+	}
+	result.Content = content
+	result.StructuredContent = redact.Value(result.StructuredContent)
+	return result
+}
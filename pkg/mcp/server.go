@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 工具处理函数，ctx 在客户端取消请求或连接关闭时被取消
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (CallToolResult, error)
+
+// 已注册的工具
+type registeredTool struct {
+	tool    Tool
+	handler ToolHandler
+}
+
+// MCP 服务器，负责工具注册和标准方法分发
+type Server struct {
+	Name    string
+	Version string
+
+	// 单条消息最大字节数，为 0 时使用 DefaultMaxMessageSize
+	MaxMessageSize int
+
+	// HTTP 会话的空闲超时和数量上限，为 0 时使用 DefaultSessionIdleTimeout 和 DefaultMaxSessions
+	SessionIdleTimeout time.Duration
+	MaxSessions        int
+
+	tools []*registeredTool
+	index map[string]*registeredTool
+}
+
+// 创建新的 MCP 服务器
+func NewServer(name, version string) *Server {
+	return &Server{
+		Name:    name,
+		Version: version,
+		index:   make(map[string]*registeredTool),
+	}
+}
+
+// 工具注册选项
+type ToolOption func(*Tool)
+
+// 声明工具的输出结构，处理函数应通过 StructuredResult 返回符合该结构的结果
+func WithOutputSchema(schema map[string]interface{}) ToolOption {
+	return func(t *Tool) {
+		t.OutputSchema = schema
+	}
+}
+
+// 声明工具注解，提示客户端工具的行为特征
+func WithAnnotations(annotations ToolAnnotations) ToolOption {
+	return func(t *Tool) {
+		t.Annotations = &annotations
+	}
+}
+
+// 注册工具，工具按注册顺序出现在 tools/list 中
+func (s *Server) RegisterTool(name, description string, inputSchema map[string]interface{}, handler ToolHandler, opts ...ToolOption) {
+	if _, exists := s.index[name]; exists {
+		panic(fmt.Sprintf("mcp: 工具 %s 重复注册", name))
+	}
+	rt := &registeredTool{
+		tool: Tool{
+			Name:        name,
+			Description: description,
+			InputSchema: inputSchema,
+		},
+		handler: handler,
+	}
+	for _, opt := range opts {
+		opt(&rt.tool)
+	}
+	s.tools = append(s.tools, rt)
+	s.index[name] = rt
+}
+
+// 返回已注册的工具列表
+func (s *Server) Tools() []Tool {
+	tools := make([]Tool, 0, len(s.tools))
+	for _, rt := range s.tools {
+		tools = append(tools, rt.tool)
+	}
+	return tools
+}
+
+// 返回调用方可见的工具列表，去掉当前协议版本不支持的字段
+func (s *Server) toolsFor(ctx context.Context) []Tool {
+	caller := CallerFromContext(ctx)
+	structured := Supports(ctx, FeatureStructuredOutput)
+	annotations := Supports(ctx, FeatureToolAnnotations)
+
+	tools := make([]Tool, 0, len(s.tools))
+	for _, rt := range s.tools {
+		if !caller.CanCall(rt.tool.Name) {
+			continue
+		}
+		tool := rt.tool
+		if !structured {
+			tool.OutputSchema = nil
+		}
+		if !annotations {
+			tool.Annotations = nil
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// initialize 响应
+func (s *Server) initializeResult(version string) map[string]interface{} {
+	return map[string]interface{}{
+		"protocolVersion": version,
+		"capabilities": map[string]interface{}{
+			"tools": map[string]interface{}{},
+		},
+		"serverInfo": map[string]interface{}{
+			"name":    s.Name,
+			"version": s.Version,
+		},
+	}
+}
+
+// 处理除 initialize 和 tools/call 以外的请求
+func (s *Server) handleRequest(ctx context.Context, request *Request) *Response {
+	switch request.Method {
+	case "ping":
+		return resultResponse(request.ID, struct{}{})
+
+	case "tools/list":
+		return resultResponse(request.ID, map[string]interface{}{
+			"tools": s.toolsFor(ctx),
+		})
+
+	default:
+		return errorResponse(request.ID, CodeMethodNotFound, "Method not found", fmt.Sprintf("Unknown method: %s", request.Method))
+	}
+}
+
+// 解析 tools/call 参数并查找工具，失败时返回错误响应
+func (s *Server) lookupTool(ctx context.Context, request *Request) (*registeredTool, map[string]interface{}, *Response) {
+	var callRequest CallToolRequest
+	if err := json.Unmarshal(request.Params, &callRequest); err != nil {
+		return nil, nil, errorResponse(request.ID, CodeInvalidParams, "Invalid params", err.Error())
+	}
+
+	rt, ok := s.index[callRequest.Name]
+	if !ok {
+		return nil, nil, errorResponse(request.ID, CodeMethodNotFound, "Method not found", fmt.Sprintf("Unknown tool: %s", callRequest.Name))
+	}
+	if !CallerFromContext(ctx).CanCall(callRequest.Name) {
+		return nil, nil, errorResponse(request.ID, CodeForbidden, "Forbidden", fmt.Sprintf("无权调用工具: %s", callRequest.Name))
+	}
+	return rt, callRequest.Arguments, nil
+}
+
+func resultResponse(id json.RawMessage, result interface{}) *Response {
+	return &Response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  result,
+	}
+}
+
+func errorResponse(id json.RawMessage, code int, message, data string) *Response {
+	response := &Response{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error: &Error{
+			Code:    code,
+			Message: message,
+		},
+	}
+	if data != "" {
+		response.Error.Message = fmt.Sprintf("%s: %s", message, data)
+	}
+	return response
+}
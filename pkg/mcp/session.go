@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// notifications/cancelled 参数
+type cancelledParams struct {
+	RequestID json.RawMessage `json:"requestId"`
+	Reason    string          `json:"reason,omitempty"`
+}
+
+// 单个客户端会话，tools/call 在独立 goroutine 中执行，
+// 其余请求同步处理，因此慢速工具调用不会阻塞 ping 等请求
+type session struct {
+	server *Server
+
+	mu         sync.Mutex
+	negotiated *negotiated
+	inflight   map[string]context.CancelFunc
+	wg         sync.WaitGroup
+}
+
+func newSession(server *Server) *session {
+	return &session{
+		server:     server,
+		negotiated: &negotiated{version: DefaultProtocolVersion},
+		inflight:   make(map[string]context.CancelFunc),
+	}
+}
+
+// 处理单条消息。每个请求恰好调用一次 reply（可能在其他 goroutine 中），
+// 请求被取消时以 nil 调用；通知消息不调用 reply
+func (s *session) handle(ctx context.Context, request *Request, reply func(*Response)) {
+	if request.IsNotification() {
+		s.handleNotification(request)
+		return
+	}
+
+	if request.JSONRPC != "2.0" || request.Method == "" {
+		reply(errorResponse(request.ID, CodeInvalidRequest, "Invalid Request", ""))
+		return
+	}
+
+	if request.Method == "initialize" {
+		reply(s.initialize(request))
+		return
+	}
+
+	s.mu.Lock()
+	ctx = withNegotiated(ctx, s.negotiated)
+	s.mu.Unlock()
+
+	if request.Method != "tools/call" {
+		reply(s.server.handleRequest(ctx, request))
+		return
+	}
+
+	rt, args, errResp := s.server.lookupTool(ctx, request)
+	if errResp != nil {
+		reply(errResp)
+		return
+	}
+
+	// 在启动 goroutine 之前登记请求，保证随后到达的取消通知一定能找到它
+	key := requestKey(request.ID)
+	callCtx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, key)
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		result, err := rt.handler(callCtx, args)
+
+		// 已取消的请求不再发送响应
+		if callCtx.Err() != nil {
+			reply(nil)
+			return
+		}
+		if err != nil {
+			result = ErrorResult(err)
+		}
+		result = redactResult(result)
+		if !Supports(callCtx, FeatureStructuredOutput) {
+			result.StructuredContent = nil
+		}
+		reply(resultResponse(request.ID, result))
+	}()
+}
+
+// 协商协议版本并记录客户端能力
+func (s *session) initialize(request *Request) *Response {
+	var params initializeParams
+	if len(request.Params) > 0 {
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return errorResponse(request.ID, CodeInvalidParams, "Invalid params", err.Error())
+		}
+	}
+
+	n := &negotiated{
+		version:      NegotiateProtocolVersion(params.ProtocolVersion),
+		capabilities: params.Capabilities,
+	}
+	s.mu.Lock()
+	s.negotiated = n
+	s.mu.Unlock()
+
+	return resultResponse(request.ID, s.server.initializeResult(n.version))
+}
+
+// 处理通知消息，通知不产生任何响应
+func (s *session) handleNotification(request *Request) {
+	switch request.Method {
+	case "notifications/initialized":
+		// 客户端已完成初始化，无需处理
+	case "notifications/cancelled":
+		var params cancelledParams
+		if err := json.Unmarshal(request.Params, &params); err != nil || len(params.RequestID) == 0 {
+			return
+		}
+		s.cancel(requestKey(params.RequestID))
+	default:
+		// 未知通知按规范直接忽略
+	}
+}
+
+// 取消进行中的请求，请求已完成时忽略
+func (s *session) cancel(key string) {
+	s.mu.Lock()
+	cancel, ok := s.inflight[key]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// 取消所有进行中的请求
+func (s *session) cancelAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, cancel := range s.inflight {
+		cancel()
+	}
+}
+
+// 等待所有进行中的工具调用结束
+func (s *session) wait() {
+	s.wg.Wait()
+}
+
+// 将请求 id 规范化为 map 键，忽略空白差异
+func requestKey(id json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, id); err != nil {
+		return string(id)
+	}
+	return buf.String()
+}
@@ -0,0 +1,64 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// 使用标准输入输出进行JSON-RPC通信
+func (s *Server) ServeStdio() error {
+	return s.Serve(os.Stdin, os.Stdout)
+}
+
+// 从 r 逐行读取消息，并将响应写入 w，通知消息不会产生输出。
+// 超过 MaxMessageSize 的消息以错误响应拒绝后继续服务，
+// 输入结束后等待进行中的工具调用完成再返回
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	reader := newMessageReader(r, s.MaxMessageSize)
+
+	// 工具调用并发执行，所有响应经由同一把锁串行写出
+	var writeMu sync.Mutex
+	encoder := json.NewEncoder(w)
+	send := func(response *Response) {
+		if response == nil {
+			return
+		}
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := encoder.Encode(response); err != nil {
+			log.Printf("编码响应失败: %v", err)
+		}
+	}
+
+	sess := newSession(s)
+	defer sess.wait()
+
+	ctx := context.Background()
+	for {
+		message, err := reader.ReadMessage()
+		if errors.Is(err, ErrMessageTooLarge) {
+			send(errorResponse(json.RawMessage("null"), CodeInvalidRequest, "Invalid Request",
+				fmt.Sprintf("%v（上限 %d 字节）", err, reader.max)))
+			continue
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var request Request
+		if err := json.Unmarshal(message, &request); err != nil {
+			send(errorResponse(json.RawMessage("null"), CodeParseError, "Parse error", err.Error()))
+			continue
+		}
+		sess.handle(ctx, &request, send)
+	}
+}
@@ -0,0 +1,229 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"securitymcp-hub/pkg/redact"
+)
+
+// 测试用服务器：echo 回显 text 参数，fail 总是返回错误，slow 一直阻塞直到被取消，
+// count 返回结构化结果
+func newTestServer() *Server {
+	server := NewServer("test-mcp", "0.0.1")
+	server.RegisterTool("echo", "回显参数",
+		map[string]interface{}{"type": "object"},
+		func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+			text, _ := args["text"].(string)
+			return TextResult(text), nil
+		})
+	server.RegisterTool("fail", "总是失败",
+		map[string]interface{}{"type": "object"},
+		func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+			return CallToolResult{}, errors.New("boom")
+		})
+	server.RegisterTool("slow", "阻塞直到被取消",
+		map[string]interface{}{"type": "object"},
+		func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+			<-ctx.Done()
+			return CallToolResult{}, ctx.Err()
+		})
+	server.RegisterTool("count", "统计参数数量",
+		map[string]interface{}{"type": "object"},
+		func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+			return StructuredResult(map[string]interface{}{"count": len(args)}), nil
+		},
+		WithOutputSchema(map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"count": map[string]interface{}{"type": "integer"}},
+		}),
+		WithAnnotations(ToolAnnotations{ReadOnlyHint: true, IdempotentHint: true}))
+	return server
+}
+
+// 读取脚本化的会话记录："->" 行为客户端输入，"<-" 行为期望的服务器输出
+func loadTranscript(t *testing.T, path string) (input string, want []string) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var in strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case strings.HasPrefix(line, "-> "):
+			in.WriteString(strings.TrimPrefix(line, "-> "))
+			in.WriteString("\n")
+		case strings.HasPrefix(line, "<- "):
+			want = append(want, strings.TrimPrefix(line, "<- "))
+		}
+	}
+	return in.String(), want
+}
+
+func TestTranscripts(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("testdata 中没有会话记录")
+	}
+
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txt"), func(t *testing.T) {
+			input, want := loadTranscript(t, path)
+
+			var out bytes.Buffer
+			if err := newTestServer().Serve(strings.NewReader(input), &out); err != nil {
+				t.Fatalf("Serve 返回错误: %v", err)
+			}
+
+			var got []string
+			scanner := bufio.NewScanner(&out)
+			for scanner.Scan() {
+				got = append(got, scanner.Text())
+			}
+
+			if len(got) != len(want) {
+				t.Fatalf("响应数量为 %d，期望 %d\n实际输出:\n%s", len(got), len(want), strings.Join(got, "\n"))
+			}
+			for i := range want {
+				if !jsonEqual(t, got[i], want[i]) {
+					t.Errorf("第 %d 条响应不符\n实际: %s\n期望: %s", i+1, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func jsonEqual(t *testing.T, a, b string) bool {
+	t.Helper()
+
+	var va, vb interface{}
+	if err := json.Unmarshal([]byte(a), &va); err != nil {
+		t.Fatalf("无效的 JSON %q: %v", a, err)
+	}
+	if err := json.Unmarshal([]byte(b), &vb); err != nil {
+		t.Fatalf("无效的 JSON %q: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// 慢速工具调用进行中时，ping 必须立即得到响应；取消后慢速调用不再响应
+func TestSlowCallDoesNotBlockPing(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestServer().Serve(inR, outW)
+		outW.Close()
+	}()
+
+	lines := bufio.NewScanner(outR)
+	write := func(line string) {
+		t.Helper()
+		if _, err := io.WriteString(inW, line+"\n"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"slow","arguments":{}}}`)
+	write(`{"jsonrpc":"2.0","id":2,"method":"ping"}`)
+
+	if !lines.Scan() {
+		t.Fatal("未收到 ping 响应")
+	}
+	if !jsonEqual(t, lines.Text(), `{"jsonrpc":"2.0","id":2,"result":{}}`) {
+		t.Fatalf("期望 ping 响应，实际: %s", lines.Text())
+	}
+
+	write(`{"jsonrpc":"2.0","method":"notifications/cancelled","params":{"requestId":1}}`)
+	inW.Close()
+
+	if err := <-done; err != nil {
+		t.Fatalf("Serve 返回错误: %v", err)
+	}
+	if lines.Scan() {
+		t.Fatalf("已取消的请求不应有响应，实际: %s", lines.Text())
+	}
+}
+
+// 超过上限的消息返回错误响应，随后的消息照常处理；默认上限远大于 bufio.Scanner 的 64 KB
+func TestOversizedMessageKeepsServing(t *testing.T) {
+	large := strings.Repeat("a", 100<<10)
+	input := `{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"echo","arguments":{"text":"` + large + `"}}}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}` + "\n"
+
+	server := newTestServer()
+	var out bytes.Buffer
+	if err := server.Serve(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Serve 返回错误: %v", err)
+	}
+	if got := strings.Count(out.String(), "\n"); got != 2 {
+		t.Fatalf("响应数量为 %d，期望 2", got)
+	}
+	if !strings.Contains(out.String(), large) {
+		t.Error("默认上限下 100 KB 的消息应被正常处理")
+	}
+
+	server.MaxMessageSize = 1024
+	out.Reset()
+	if err := server.Serve(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Serve 返回错误: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("响应数量为 %d，期望 2\n%s", len(lines), out.String())
+	}
+	if !jsonEqual(t, lines[0], `{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request: 消息超过最大长度（上限 1024 字节）"}}`) {
+		t.Errorf("超长消息的响应不符: %s", lines[0])
+	}
+	if !jsonEqual(t, lines[1], `{"jsonrpc":"2.0","id":2,"result":{}}`) {
+		t.Errorf("超长消息之后的 ping 响应不符: %s", lines[1])
+	}
+}
+
+// 错误信息、文本结果和结构化结果中的凭证都不会发送给客户端
+func TestResultsAreRedacted(t *testing.T) {
+	const secret = "mcp-test-secret-0123456789"
+	redact.Add(secret)
+
+	server := NewServer("test-mcp", "0.0.1")
+	server.RegisterTool("leak", "返回包含凭证的结果",
+		map[string]interface{}{"type": "object"},
+		func(ctx context.Context, args map[string]interface{}) (CallToolResult, error) {
+			if args["fail"] == true {
+				return CallToolResult{}, fmt.Errorf(`请求失败: Get "https://fofa.info/api/v1/info/my?email=a%%40b.com&key=%s": EOF`, secret)
+			}
+			return StructuredResult(map[string]interface{}{"note": "key " + secret}), nil
+		})
+
+	input := `{"jsonrpc":"2.0","id":0,"method":"initialize","params":{"protocolVersion":"2025-06-18"}}` + "\n" +
+		`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"leak","arguments":{"fail":true}}}` + "\n" +
+		`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"leak","arguments":{}}}` + "\n"
+	var out bytes.Buffer
+	if err := server.Serve(strings.NewReader(input), &out); err != nil {
+		t.Fatalf("Serve 返回错误: %v", err)
+	}
+	if strings.Contains(out.String(), secret) || strings.Contains(out.String(), "a%40b.com") {
+		t.Fatalf("响应中包含凭证:\n%s", out.String())
+	}
+	if strings.Count(out.String(), redact.Mask) < 3 {
+		t.Fatalf("响应中缺少替换标记:\n%s", out.String())
+	}
+}
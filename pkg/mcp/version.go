@@ -0,0 +1,95 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// 支持的协议版本，从新到旧排列。协议版本为日期字符串，可直接按字典序比较
+var SupportedProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// 完成 initialize 之前使用的协议版本，只启用最早版本的功能
+const DefaultProtocolVersion = "2024-11-05"
+
+// 受协议版本限制的功能
+type Feature int
+
+const (
+	// 工具注解（annotations）
+	FeatureToolAnnotations Feature = iota
+	// 结构化工具输出（outputSchema / structuredContent）
+	FeatureStructuredOutput
+	// 服务器向客户端请求补充信息（elicitation/create），还要求客户端声明 elicitation 能力
+	FeatureElicitation
+)
+
+// 各功能引入的协议版本
+var featureVersions = map[Feature]string{
+	FeatureToolAnnotations:  "2025-03-26",
+	FeatureStructuredOutput: "2025-06-18",
+	FeatureElicitation:      "2025-06-18",
+}
+
+// 协商结果：协议版本和客户端声明的能力
+type negotiated struct {
+	version      string
+	capabilities map[string]json.RawMessage
+}
+
+// initialize 参数
+type initializeParams struct {
+	ProtocolVersion string                     `json:"protocolVersion"`
+	Capabilities    map[string]json.RawMessage `json:"capabilities"`
+}
+
+// 选择双方都支持的最新版本：支持客户端请求的版本时原样返回，
+// 否则返回不晚于该版本的最新支持版本；客户端版本早于所有支持版本时返回最新版本
+func NegotiateProtocolVersion(requested string) string {
+	for _, version := range SupportedProtocolVersions {
+		if version <= requested {
+			return version
+		}
+	}
+	return SupportedProtocolVersions[0]
+}
+
+// 判断协议版本是否受支持
+func isSupportedProtocolVersion(version string) bool {
+	for _, v := range SupportedProtocolVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+type negotiatedKey struct{}
+
+func withNegotiated(ctx context.Context, n *negotiated) context.Context {
+	return context.WithValue(ctx, negotiatedKey{}, n)
+}
+
+func negotiatedFromContext(ctx context.Context) *negotiated {
+	if n, ok := ctx.Value(negotiatedKey{}).(*negotiated); ok {
+		return n
+	}
+	return &negotiated{version: DefaultProtocolVersion}
+}
+
+// 返回当前会话协商的协议版本
+func ProtocolVersionFromContext(ctx context.Context) string {
+	return negotiatedFromContext(ctx).version
+}
+
+// 判断当前会话是否可以使用指定功能
+func Supports(ctx context.Context, feature Feature) bool {
+	n := negotiatedFromContext(ctx)
+	if n.version < featureVersions[feature] {
+		return false
+	}
+	if feature == FeatureElicitation {
+		_, ok := n.capabilities["elicitation"]
+		return ok
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestNegotiateProtocolVersion(t *testing.T) {
+	cases := map[string]string{
+		"2025-06-18": "2025-06-18",
+		"2025-03-26": "2025-03-26",
+		"2024-11-05": "2024-11-05",
+		"2025-04-01": "2025-03-26",
+		"2099-01-01": "2025-06-18",
+		"2024-01-01": "2025-06-18",
+		"":           "2025-06-18",
+	}
+	for requested, want := range cases {
+		if got := NegotiateProtocolVersion(requested); got != want {
+			t.Errorf("NegotiateProtocolVersion(%q) = %q，期望 %q", requested, got, want)
+		}
+	}
+}
+
+func TestSupports(t *testing.T) {
+	elicitation := map[string]json.RawMessage{"elicitation": json.RawMessage("{}")}
+
+	cases := []struct {
+		version      string
+		capabilities map[string]json.RawMessage
+		feature      Feature
+		want         bool
+	}{
+		{"2024-11-05", nil, FeatureToolAnnotations, false},
+		{"2025-03-26", nil, FeatureToolAnnotations, true},
+		{"2025-03-26", nil, FeatureStructuredOutput, false},
+		{"2025-06-18", nil, FeatureStructuredOutput, true},
+		{"2025-03-26", elicitation, FeatureElicitation, false},
+		{"2025-06-18", nil, FeatureElicitation, false},
+		{"2025-06-18", elicitation, FeatureElicitation, true},
+	}
+	for _, c := range cases {
+		ctx := withNegotiated(context.Background(), &negotiated{version: c.version, capabilities: c.capabilities})
+		if got := Supports(ctx, c.feature); got != c.want {
+			t.Errorf("版本 %s 能力 %v 功能 %d: Supports = %v，期望 %v", c.version, c.capabilities, c.feature, got, c.want)
+		}
+	}
+
+	if Supports(context.Background(), FeatureToolAnnotations) {
+		t.Error("未完成 initialize 时不应启用新版本功能")
+	}
+}
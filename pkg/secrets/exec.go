@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 辅助命令的默认超时时间
+const DefaultExecTimeout = 10 * time.Second
+
+// 外部辅助命令。以凭证名称作为最后一个参数调用命令，标准输出去除首尾空白后作为凭证；
+// 输出为空或退出码为 NotFoundCode 表示未找到，其他非 0 退出码视为错误。命令不经过 shell 解析
+type Exec struct {
+	Command []string
+	Timeout time.Duration
+	// 表示凭证不存在的退出码，例如 pass show 为 1，security find-generic-password 为 44；
+	// 为 0 时不区分
+	NotFoundCode int
+}
+
+// 按空白分隔的命令行创建辅助命令，例如 "pass show securitymcp"
+func NewExec(command string) (*Exec, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("辅助命令不能为空")
+	}
+	return &Exec{Command: args, Timeout: DefaultExecTimeout}, nil
+}
+
+func (e *Exec) Name() string { return "辅助命令" }
+
+func (e *Exec) Lookup(ctx context.Context, name string) (string, bool, error) {
+	if e.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.Timeout)
+		defer cancel()
+	}
+	args := append(append([]string{}, e.Command[1:]...), name)
+	cmd := exec.CommandContext(ctx, e.Command[0], args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return "", false, fmt.Errorf("%s 执行超时", e.Command[0])
+		}
+		var exitErr *exec.ExitError
+		if e.NotFoundCode != 0 && errors.As(err, &exitErr) && exitErr.ExitCode() == e.NotFoundCode {
+			return "", false, nil
+		}
+		// 标准错误只取第一行，避免把大段输出带入日志
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if msg != "" {
+			return "", false, fmt.Errorf("%s: %w: %s", e.Command[0], err, msg)
+		}
+		return "", false, fmt.Errorf("%s: %w", e.Command[0], err)
+	}
+	value := strings.TrimSpace(stdout.String())
+	return value, value != "", nil
+}
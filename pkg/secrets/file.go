@@ -0,0 +1,196 @@
+package secrets
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 加密凭证文件的格式版本和密钥派生参数
+const (
+	fileVersion = 1
+	kdfName     = "pbkdf2-sha256"
+
+	// 新建文件时的 PBKDF2 迭代次数
+	DefaultIterations = 600000
+)
+
+// 加密凭证文件的内容：用口令经 PBKDF2-HMAC-SHA256 派生密钥，以 AES-256-GCM 加密
+// 名称到凭证的 JSON 对象
+type envelope struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// 已解密的凭证文件
+type File struct {
+	values map[string]string
+}
+
+// 读取并解密凭证文件
+func OpenFile(path string, passphrase []byte) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取加密凭证文件失败: %w", err)
+	}
+	values, err := Decrypt(data, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("加密凭证文件 %s: %w", path, err)
+	}
+	return &File{values: values}, nil
+}
+
+func (f *File) Name() string { return "加密凭证文件" }
+
+func (f *File) Lookup(_ context.Context, name string) (string, bool, error) {
+	value, ok := f.values[name]
+	return value, ok && value != "", nil
+}
+
+// 凭证名称，不包含值
+func (f *File) Names() []string {
+	names := make([]string, 0, len(f.values))
+	for name := range f.values {
+		names = append(names, name)
+	}
+	return names
+}
+
+// 加密名称到凭证的映射，返回凭证文件内容
+func Encrypt(values map[string]string, passphrase []byte, iterations int) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("口令不能为空")
+	}
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	plaintext, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	env := envelope{Version: fileVersion, KDF: kdfName, Iterations: iterations, Salt: make([]byte, 16)}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(passphrase, env.Salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, err
+	}
+	env.Data = aead.Seal(nil, env.Nonce, plaintext, nil)
+	out, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// 解密凭证文件内容
+func Decrypt(data, passphrase []byte) (map[string]string, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("文件格式错误: %w", err)
+	}
+	if env.Version != fileVersion || env.KDF != kdfName {
+		return nil, fmt.Errorf("不支持的文件版本 %d（%s）", env.Version, env.KDF)
+	}
+	if env.Iterations <= 0 || len(env.Salt) == 0 {
+		return nil, fmt.Errorf("文件格式错误: 缺少密钥派生参数")
+	}
+	aead, err := newAEAD(passphrase, env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("文件格式错误: nonce 长度为 %d", len(env.Nonce))
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("解密失败，口令错误或文件已损坏")
+	}
+	var values map[string]string
+	if err := json.Unmarshal(plaintext, &values); err != nil {
+		return nil, fmt.Errorf("解密后的内容格式错误")
+	}
+	return values, nil
+}
+
+func newAEAD(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256(passphrase, salt, iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// PBKDF2-HMAC-SHA256（RFC 8018）
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	u := make([]byte, 0, sha256.Size)
+	t := make([]byte, sha256.Size)
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(binary.BigEndian.AppendUint32(nil, block))
+		u = prf.Sum(u[:0])
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// 解析 NAME=value 格式的凭证列表，用于生成加密凭证文件。
+// 空行和 # 开头的行会被忽略，值两侧的引号会被去除；错误信息中不包含值
+func ParseEnv(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("第 %d 行格式错误，应为 NAME=value", lineNo)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,19 @@
+package secrets
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 收到 SIGHUP 时调用 reload，用于在不重启服务的情况下轮换凭证。
+// 依次处理信号，reload 执行期间收到的信号会在结束后再触发一次
+func OnReload(reload func()) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	go func() {
+		for range signals {
+			reload()
+		}
+	}()
+}
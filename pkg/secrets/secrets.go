@@ -0,0 +1,132 @@
+// Package secrets 从多个来源读取 API 凭证，避免凭证只能通过环境变量传入。
+//
+// 按名称（例如 FOFA_KEY）依次查找：
+//
+//  1. 环境变量 NAME
+//  2. 环境变量 NAME_FILE 指定的文件，适用于以文件形式挂载的密钥
+//  3. 加密的凭证文件
+//  4. 外部辅助命令，例如从系统钥匙串或密钥管理服务读取
+//
+// 第一个找到的值生效。后两种来源需要在配置中启用。
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 凭证来源
+type Provider interface {
+	// 来源名称，用于错误信息
+	Name() string
+	// 查找名为 name 的凭证，未找到时返回 false
+	Lookup(ctx context.Context, name string) (string, bool, error)
+}
+
+// 凭证来源的配置
+type Options struct {
+	File           string // 加密的凭证文件，为空时不使用
+	PassphraseFile string // 加密凭证文件的口令文件
+	Exec           string // 辅助命令，为空时不使用
+	ExecNotFound   int    // 辅助命令表示凭证不存在的退出码，为 0 时不区分
+}
+
+// 按配置创建凭证来源：环境变量、NAME_FILE、加密凭证文件、辅助命令。
+// 加密凭证文件在创建时读取并解密，重新加载凭证时应重新调用 Open
+func Open(opts Options) (Chain, error) {
+	chain := Chain{Env{}, EnvFile{}}
+	if opts.File != "" {
+		if opts.PassphraseFile == "" {
+			return nil, fmt.Errorf("使用加密凭证文件时必须设置口令文件")
+		}
+		passphrase, err := ReadPassphrase(opts.PassphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		file, err := OpenFile(opts.File, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, file)
+	} else if opts.PassphraseFile != "" {
+		return nil, fmt.Errorf("设置了口令文件但未设置加密凭证文件")
+	}
+	if opts.Exec != "" {
+		helper, err := NewExec(opts.Exec)
+		if err != nil {
+			return nil, err
+		}
+		helper.NotFoundCode = opts.ExecNotFound
+		chain = append(chain, helper)
+	}
+	return chain, nil
+}
+
+// 依次查找的多个来源
+type Chain []Provider
+
+// 返回第一个找到的值，任一来源出错时返回错误
+func (c Chain) Lookup(ctx context.Context, name string) (string, bool, error) {
+	for _, p := range c {
+		value, ok, err := p.Lookup(ctx, name)
+		if err != nil {
+			return "", false, fmt.Errorf("从%s读取 %s 失败: %w", p.Name(), name, err)
+		}
+		if ok {
+			return value, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// 查找凭证，未找到时返回空字符串
+func (c Chain) Get(ctx context.Context, name string) (string, error) {
+	value, _, err := c.Lookup(ctx, name)
+	return value, err
+}
+
+// 环境变量
+type Env struct{}
+
+func (Env) Name() string { return "环境变量" }
+
+func (Env) Lookup(_ context.Context, name string) (string, bool, error) {
+	value := os.Getenv(name)
+	return value, value != "", nil
+}
+
+// 环境变量 NAME_FILE 指定的文件，文件内容去除首尾空白后作为凭证
+type EnvFile struct{}
+
+func (EnvFile) Name() string { return "凭证文件" }
+
+func (EnvFile) Lookup(_ context.Context, name string) (string, bool, error) {
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("读取 %s_FILE 指定的文件失败: %w", name, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return "", false, fmt.Errorf("%s_FILE 指定的文件 %s 为空", name, path)
+	}
+	return value, true, nil
+}
+
+// 读取口令文件，去除末尾的换行
+func ReadPassphrase(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取口令文件失败: %w", err)
+	}
+	passphrase := []byte(strings.TrimRight(string(data), "\r\n"))
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("口令文件 %s 为空", path)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestChainOrder(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "fofa_key")
+	os.WriteFile(keyFile, []byte("from-file\n"), 0o600)
+
+	t.Setenv("TEST_FOFA_KEY", "")
+	t.Setenv("TEST_FOFA_KEY_FILE", keyFile)
+	chain := Chain{Env{}, EnvFile{}}
+	if value, err := chain.Get(context.Background(), "TEST_FOFA_KEY"); err != nil || value != "from-file" {
+		t.Fatalf("value=%q, err=%v", value, err)
+	}
+
+	// 环境变量优先
+	t.Setenv("TEST_FOFA_KEY", "from-env")
+	if value, _ := chain.Get(context.Background(), "TEST_FOFA_KEY"); value != "from-env" {
+		t.Fatalf("value=%q", value)
+	}
+
+	t.Setenv("TEST_FOFA_KEY", "")
+	t.Setenv("TEST_FOFA_KEY_FILE", filepath.Join(dir, "missing"))
+	if _, err := chain.Get(context.Background(), "TEST_FOFA_KEY"); err == nil {
+		t.Fatal("文件不存在时应报错")
+	}
+}
+
+func TestEncryptedFile(t *testing.T) {
+	values, err := ParseEnv(strings.NewReader("# FOFA\nFOFA_EMAIL=a@example.com\nexport FOFA_KEY=\"secret-key\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Encrypt(values, []byte("passphrase"), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "secret-key") || strings.Contains(string(data), "a@example.com") {
+		t.Fatal("加密后的文件中包含明文")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.enc")
+	passFile := filepath.Join(dir, "passphrase")
+	os.WriteFile(path, data, 0o600)
+	os.WriteFile(passFile, []byte("passphrase\n"), 0o600)
+
+	chain, err := Open(Options{File: path, PassphraseFile: passFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := chain.Get(context.Background(), "FOFA_KEY"); err != nil || value != "secret-key" {
+		t.Fatalf("value=%q, err=%v", value, err)
+	}
+
+	if _, err := OpenFile(path, []byte("wrong")); err == nil || strings.Contains(err.Error(), "secret-key") {
+		t.Fatalf("口令错误时错误为 %v", err)
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	// RFC 7914 第 11 节的 PBKDF2-HMAC-SHA256 测试向量
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got := hex.EncodeToString(key); got != want {
+		t.Fatalf("派生结果为 %s", got)
+	}
+}
+
+func TestExec(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("辅助命令测试使用 shell 脚本")
+	}
+	script := filepath.Join(t.TempDir(), "helper.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\ncase \"$2\" in\nFOFA_KEY) echo helper-key ;;\nBAD) echo denied >&2; exit 1 ;;\nesac\n"), 0o700)
+
+	helper, err := NewExec(script + " get")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok, err := helper.Lookup(context.Background(), "FOFA_KEY"); err != nil || !ok || value != "helper-key" {
+		t.Fatalf("value=%q, ok=%v, err=%v", value, ok, err)
+	}
+	if _, ok, err := helper.Lookup(context.Background(), "OTHER"); err != nil || ok {
+		t.Fatalf("ok=%v, err=%v", ok, err)
+	}
+	if _, _, err := helper.Lookup(context.Background(), "BAD"); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Fatalf("错误为 %v", err)
+	}
+}
+
+func TestExecNotFoundCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("辅助命令测试使用 shell 脚本")
+	}
+	// 与 pass show 相同，条目不存在时以退出码 1 结束
+	script := filepath.Join(t.TempDir(), "helper.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\ncase \"$2\" in\nFOFA_KEY) echo helper-key ;;\nBAD) echo denied >&2; exit 2 ;;\n*) echo \"$2 is not in the password store.\" >&2; exit 1 ;;\nesac\n"), 0o700)
+
+	chain, err := Open(Options{Exec: script + " show", ExecNotFound: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := chain.Get(context.Background(), "FOFA_KEY"); err != nil || value != "helper-key" {
+		t.Fatalf("value=%q, err=%v", value, err)
+	}
+	if value, err := chain.Get(context.Background(), "FOFA_KEYS"); err != nil || value != "" {
+		t.Fatalf("退出码 1 应视为未找到: value=%q, err=%v", value, err)
+	}
+	if _, err := chain.Get(context.Background(), "BAD"); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Fatalf("其他退出码应视为错误: %v", err)
+	}
+}
@@ -1,13 +1,14 @@
 #!/bin/bash
 
 # SecurityMCP-Hub 构建脚本
-# 用于构建所有 MCP 服务
+# 用于构建所有 MCP 服务和 cmd 下的命令行工具
 
 set -e
 
 SCRIPT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)"
 PROJECT_ROOT="$(cd "$SCRIPT_DIR/.." && pwd)"
 SERVERS_DIR="$PROJECT_ROOT/servers"
+CMD_DIR="$PROJECT_ROOT/cmd"
 
 echo "开始构建 SecurityMCP-Hub 服务..."
 
@@ -37,6 +38,21 @@ for service_dir in "$SERVERS_DIR"/*; do
     fi
 done
 
+# 遍历所有命令行工具目录
+for tool_dir in "$CMD_DIR"/*; do
+    if [ -d "$tool_dir" ] && [ -f "$tool_dir/go.mod" ]; then
+        tool_name=$(basename "$tool_dir")
+        echo ""
+        echo "构建工具: $tool_name"
+        echo "----------------------------------------"
+
+        cd "$tool_dir"
+        go build -o "$tool_name" .
+        echo "✓ $tool_name 构建成功"
+        cd "$PROJECT_ROOT"
+    fi
+done
+
 echo ""
 echo "构建完成！"
 
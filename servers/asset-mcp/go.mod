@@ -0,0 +1,17 @@
+module asset-mcp
+
+go 1.21
+
+require (
+	fofa-mcp v0.0.0
+	securitymcp-hub/pkg v0.0.0
+	zoomeye-mcp v0.0.0
+)
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace (
+	fofa-mcp => ../fofa-mcp
+	securitymcp-hub/pkg => ../../pkg
+	zoomeye-mcp => ../zoomeye-mcp
+)
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"asset-mcp/src"
+	fofa "fofa-mcp/src"
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/mcp"
+	"securitymcp-hub/pkg/redact"
+	"securitymcp-hub/pkg/secrets"
+	zoomeye "zoomeye-mcp/src"
+)
+
+func main() {
+	// 日志中的凭证替换为 [REDACTED]
+	log.SetOutput(redact.Writer(os.Stderr))
+
+	flags := mcp.RegisterFlags(flag.CommandLine)
+	configPath := flag.String("config", os.Getenv("ASSET_MCP_CONFIG"), "配置文件路径（YAML），也可通过环境变量 ASSET_MCP_CONFIG 设置")
+	flag.Parse()
+
+	cfg, err := src.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
+
+	// 获取凭证，未设置凭证的引擎不参与查询
+	aggregator := &src.Aggregator{}
+	fofaKeys, zoomEyeKeys, err := loadKeys(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(fofaKeys) > 0 {
+		aggregator.Fofa, err = fofa.NewFofaClientWithKeys(fofaKeys, cfg.FofaClientConfig())
+		if err != nil {
+			log.Fatalf("创建FOFA客户端失败: %v", err)
+		}
+	}
+	if len(zoomEyeKeys) > 0 {
+		aggregator.ZoomEye, err = zoomeye.NewZoomEyeClientWithKeys(zoomEyeKeys, cfg.ZoomEyeClientConfig())
+		if err != nil {
+			log.Fatalf("创建 ZoomEye 客户端失败: %v", err)
+		}
+	}
+	if len(aggregator.Engines()) == 0 {
+		log.Fatal("请设置环境变量 FOFA_EMAIL 和 FOFA_KEY（或 FOFA_KEYS），或 ZOOMEYE_API_KEY（或 ZOOMEYE_API_KEYS），至少配置一个搜索引擎；也可以使用 _FILE 文件、加密凭证文件或辅助命令")
+	}
+
+	// 收到 SIGHUP 时重新读取凭证，失败时继续使用原有凭证。
+	// 启动时未配置凭证的引擎需要重启服务才能启用
+	secrets.OnReload(func() {
+		fofaKeys, zoomEyeKeys, err := loadKeys(cfg)
+		if err != nil {
+			log.Printf("重新加载凭证失败，继续使用原有凭证: %v", err)
+			return
+		}
+		if aggregator.Fofa != nil {
+			reloadKeys("FOFA", aggregator.Fofa.Keys, fofaKeys)
+		}
+		if aggregator.ZoomEye != nil {
+			reloadKeys("ZoomEye", aggregator.ZoomEye.Keys, zoomEyeKeys)
+		}
+	})
+
+	server := mcp.NewServer(cfg.Server.Name, cfg.Server.Version)
+	registerTools(server, aggregator)
+
+	if err := server.Run(flags); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// 按配置的凭证来源读取两个引擎的 API Key
+func loadKeys(cfg *src.Config) (fofaKeys, zoomEyeKeys []keypool.Key, err error) {
+	store, err := secrets.Open(cfg.Secrets.Options())
+	if err != nil {
+		return nil, nil, err
+	}
+	if fofaKeys, err = fofa.LoadKeys(context.Background(), store); err != nil {
+		return nil, nil, err
+	}
+	if zoomEyeKeys, err = zoomeye.LoadKeys(context.Background(), store); err != nil {
+		return nil, nil, err
+	}
+	return fofaKeys, zoomEyeKeys, nil
+}
+
+// 替换引擎的 API Key，失败时继续使用原有的 key
+func reloadKeys(engine string, pool *keypool.Pool, keys []keypool.Key) {
+	if err := pool.Replace(keys); err != nil {
+		log.Printf("重新加载 %s 凭证失败，继续使用原有凭证: %v", engine, err)
+		return
+	}
+	log.Printf("已重新加载 %d 个 %s API Key", len(keys), engine)
+}
+
+// 注册聚合搜索工具
+func registerTools(server *mcp.Server, aggregator *src.Aggregator) {
+	server.RegisterTool("asset_search", `同时在 FOFA 和 ZoomEye 中搜索资产，并将结果合并为统一的资产列表。
+
+查询语句按 syntax 指定的语法（默认为 FOFA 语法）编写，发给另一个引擎前会自动翻译，无法翻译的条件会被省略并在对应引擎的 warnings 中说明；也可以通过 fofa_query、zoomeye_query 为每个引擎单独指定查询语句。
+
+每条资产包含 ip、port、protocol、domain、title、server、country、asn、cert_subject、last_seen 字段，同一 ip:port 只保留一条，engines 列出发现该资产的引擎。单个引擎查询失败时，其余引擎的结果照常返回，错误记录在 engines 中。`,
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": `查询语句，例如：title="login" && country="CN"`,
+				},
+				"syntax": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"fofa", "zoomeye"},
+					"description": "query 使用的查询语法，默认为 fofa",
+					"default":     "fofa",
+				},
+				"fofa_query": map[string]interface{}{
+					"type":        "string",
+					"description": "发给 FOFA 的查询语句，设置后不再由 query 翻译",
+				},
+				"zoomeye_query": map[string]interface{}{
+					"type":        "string",
+					"description": "发给 ZoomEye 的查询语句，设置后不再由 query 翻译",
+				},
+				"engines": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string", "enum": []string{"fofa", "zoomeye"}},
+					"description": "要查询的引擎，默认为所有已配置凭证的引擎",
+				},
+				"size": map[string]interface{}{
+					"type":        "integer",
+					"description": "每个引擎返回的结果数量，范围1-10000，默认为100",
+					"default":     100,
+				},
+				"cache": cache.ArgumentSchema,
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleAssetSearch(ctx, aggregator, args)
+		},
+		mcp.WithOutputSchema(assetSearchOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "FOFA / ZoomEye 聚合搜索", ReadOnlyHint: true, OpenWorldHint: true}))
+}
+
+// asset_search 结构化输出
+type assetSearchOutput struct {
+	Success bool               `json:"success"`
+	Query   string             `json:"query"`
+	Total   int                `json:"total"` // 去重后的资产数量
+	Engines []src.EngineResult `json:"engines"`
+	Assets  []src.Asset        `json:"assets"`
+	Cache   *cache.Result      `json:"cache"` // 所有引擎合计的缓存命中情况
+}
+
+var assetSearchOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success": map[string]interface{}{"type": "boolean"},
+		"query":   map[string]interface{}{"type": "string"},
+		"total":   map[string]interface{}{"type": "integer", "description": "去重后的资产数量"},
+		"engines": map[string]interface{}{
+			"type":        "array",
+			"description": "各引擎的查询情况",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"engine":   map[string]interface{}{"type": "string"},
+					"query":    map[string]interface{}{"type": "string", "description": "实际发给该引擎的查询语句"},
+					"total":    map[string]interface{}{"type": "integer", "description": "引擎报告的结果总数"},
+					"returned": map[string]interface{}{"type": "integer", "description": "本次返回的结果数量"},
+					"error":    map[string]interface{}{"type": "string", "description": "查询失败的原因"},
+					"warnings": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+					"budget":   budget.ResultSchema,
+					"keys":     keypool.ResultSchema,
+				},
+				"required": []string{"engine", "total", "returned"},
+			},
+		},
+		"assets": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"ip":           map[string]interface{}{"type": "string"},
+					"port":         map[string]interface{}{"type": "integer"},
+					"protocol":     map[string]interface{}{"type": "string"},
+					"domain":       map[string]interface{}{"type": "string"},
+					"title":        map[string]interface{}{"type": "string"},
+					"server":       map[string]interface{}{"type": "string"},
+					"country":      map[string]interface{}{"type": "string"},
+					"asn":          map[string]interface{}{"type": "integer"},
+					"cert_subject": map[string]interface{}{"type": "string", "description": "证书主题 CN"},
+					"last_seen":    map[string]interface{}{"type": "string", "format": "date-time"},
+					"engines": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "发现该资产的引擎",
+					},
+				},
+				"required": []string{"ip", "port", "engines"},
+			},
+		},
+		"cache": cache.ResultSchema,
+	},
+	"required": []string{"success", "query", "total", "engines", "assets", "cache"},
+}
+
+func handleAssetSearch(ctx context.Context, aggregator *src.Aggregator, args map[string]interface{}) (mcp.CallToolResult, error) {
+	query, ok := args["query"].(string)
+	if !ok || query == "" {
+		return mcp.CallToolResult{}, fmt.Errorf("query参数是必需的")
+	}
+
+	params := src.SearchParams{Query: query, Size: 100}
+	if syntax, ok := args["syntax"].(string); ok {
+		params.Syntax = syntax
+	}
+	if q, ok := args["fofa_query"].(string); ok {
+		params.FofaQuery = q
+	}
+	if q, ok := args["zoomeye_query"].(string); ok {
+		params.ZoomEyeQuery = q
+	}
+	if engines, ok := args["engines"].([]interface{}); ok {
+		for _, engine := range engines {
+			name, ok := engine.(string)
+			if !ok {
+				return mcp.CallToolResult{}, fmt.Errorf("engines参数必须为字符串数组")
+			}
+			params.Engines = append(params.Engines, name)
+		}
+	}
+	if size, ok := args["size"].(float64); ok {
+		if size < 1 || size > 10000 {
+			return mcp.CallToolResult{}, fmt.Errorf("size参数必须在1-10000之间")
+		}
+		params.Size = int(size)
+	}
+
+	ctx, cacheStatus, err := cache.FromArgs(ctx, args)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
+
+	result, err := aggregator.Search(ctx, params)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
+
+	return mcp.StructuredResult(assetSearchOutput{
+		Success: true,
+		Query:   query,
+		Total:   len(result.Assets),
+		Engines: result.Engines,
+		Assets:  result.Assets,
+		Cache:   cacheStatus.Result(),
+	}), nil
+}
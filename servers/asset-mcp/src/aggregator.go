@@ -0,0 +1,230 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	fofa "fofa-mcp/src"
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/dork"
+	"securitymcp-hub/pkg/keypool"
+	zoomeye "zoomeye-mcp/src"
+)
+
+// 同时查询 FOFA 和 ZoomEye 并合并结果，未配置凭证的引擎为 nil
+type Aggregator struct {
+	Fofa    *fofa.FofaClient
+	ZoomEye *zoomeye.ZoomEyeClient
+}
+
+// 聚合搜索参数
+type SearchParams struct {
+	Query        string   // 查询语句
+	Syntax       string   // Query 使用的语法：fofa 或 zoomeye，默认为 fofa
+	FofaQuery    string   // 发给 FOFA 的查询语句，为空时由 Query 翻译
+	ZoomEyeQuery string   // 发给 ZoomEye 的查询语句，为空时由 Query 翻译
+	Engines      []string // 要查询的引擎，为空时查询所有已配置的引擎
+	Size         int      // 每个引擎返回的结果数量
+}
+
+// 单个引擎的查询情况
+type EngineResult struct {
+	Engine   string   `json:"engine"`
+	Query    string   `json:"query,omitempty"`
+	Total    int      `json:"total"`    // 引擎报告的结果总数
+	Returned int      `json:"returned"` // 本次返回的结果数量
+	Error    string   `json:"error,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+	// 该引擎的用量预算，未配置预算时省略
+	Budget *budget.Report `json:"budget,omitempty"`
+	// 该引擎使用的 API Key 名称
+	Keys *keypool.Result `json:"keys,omitempty"`
+
+	assets []Asset
+}
+
+// 聚合搜索结果
+type SearchResult struct {
+	Engines []EngineResult
+	Assets  []Asset
+}
+
+// 已配置凭证的引擎
+func (a *Aggregator) Engines() []string {
+	var engines []string
+	if a.Fofa != nil {
+		engines = append(engines, EngineFofa)
+	}
+	if a.ZoomEye != nil {
+		engines = append(engines, EngineZoomEye)
+	}
+	return engines
+}
+
+// 并行查询各引擎，按 ip:port 合并结果。单个引擎失败时在对应的 EngineResult 中
+// 记录错误，其余引擎的结果照常返回；所有引擎都失败时返回错误
+func (a *Aggregator) Search(ctx context.Context, params SearchParams) (*SearchResult, error) {
+	if params.Size < 1 {
+		params.Size = 100
+	}
+	if params.Syntax == "" {
+		params.Syntax = EngineFofa
+	}
+	syntax, err := dork.Lookup(params.Syntax)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := syntax.Parse(params.Query); err != nil {
+		return nil, fmt.Errorf("查询语句语法错误，%w", err)
+	}
+
+	engines := params.Engines
+	if len(engines) == 0 {
+		engines = a.Engines()
+	}
+	var results []EngineResult
+	var selected []string
+	for _, engine := range engines {
+		engine = strings.ToLower(strings.TrimSpace(engine))
+		if !containsString(a.Engines(), engine) {
+			return nil, fmt.Errorf("引擎 %s 未配置凭证或不受支持，可用的引擎：%s", engine, strings.Join(a.Engines(), "、"))
+		}
+		if !containsString(selected, engine) {
+			selected = append(selected, engine)
+			results = append(results, EngineResult{Engine: engine})
+		}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("没有可用的搜索引擎")
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		result := &results[i]
+		query, err := a.engineQuery(result, syntax, params)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		result.Query = query
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, keyUsage := keypool.Track(ctx)
+			var err error
+			switch result.Engine {
+			case EngineFofa:
+				err = a.searchFofa(ctx, result, params.Size)
+			case EngineZoomEye:
+				err = a.searchZoomEye(ctx, result, params.Size)
+			}
+			result.Keys = keyUsage.Result()
+			if err != nil {
+				result.Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range results {
+		switch results[i].Engine {
+		case EngineFofa:
+			results[i].Budget = a.Fofa.Budget.Report()
+		case EngineZoomEye:
+			results[i].Budget = a.ZoomEye.Budget.Report()
+		}
+	}
+
+	var groups [][]Asset
+	var errs []string
+	for _, result := range results {
+		if result.Error != "" {
+			errs = append(errs, fmt.Sprintf("%s: %s", result.Engine, result.Error))
+			continue
+		}
+		groups = append(groups, result.assets)
+	}
+	if len(groups) == 0 {
+		return nil, fmt.Errorf("所有搜索引擎均查询失败: %s", strings.Join(errs, "; "))
+	}
+
+	return &SearchResult{Engines: results, Assets: MergeAssets(groups...)}, nil
+}
+
+// 确定发给引擎的查询语句：优先使用单独指定的语句，否则将 Query 翻译为引擎的语法，
+// 部分条件无法翻译时在 warnings 中说明
+func (a *Aggregator) engineQuery(result *EngineResult, syntax *dork.Dialect, params SearchParams) (string, error) {
+	dialect, _ := dork.Lookup(result.Engine)
+	override := params.FofaQuery
+	if result.Engine == EngineZoomEye {
+		override = params.ZoomEyeQuery
+	}
+
+	query := params.Query
+	switch {
+	case override != "":
+		query = override
+	case dialect != syntax:
+		translation, err := dork.Translate(params.Query, syntax, dialect)
+		if err != nil {
+			return "", fmt.Errorf("查询语句无法翻译为 %s 语法: %w", dialect.Name, err)
+		}
+		query = translation.Translated
+		for _, u := range translation.Untranslated {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("条件 %s 无法翻译，已省略：%s", u.Clause, u.Reason))
+		}
+		result.Warnings = append(result.Warnings, translation.Notes...)
+	}
+
+	warnings, err := dialect.Check(query)
+	if err != nil {
+		return "", err
+	}
+	result.Warnings = append(result.Warnings, warnings...)
+	return query, nil
+}
+
+func (a *Aggregator) searchFofa(ctx context.Context, result *EngineResult, size int) error {
+	check, err := a.Fofa.CheckFields(ctx, FofaFields)
+	if err != nil {
+		return err
+	}
+	result.Warnings = append(result.Warnings, check.Warnings...)
+
+	resp, err := a.Fofa.Search(ctx, fofa.QueryParams{
+		Query:  result.Query,
+		Size:   size,
+		Fields: check.Fields.String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	result.Total = resp.Size
+	result.Returned = len(resp.Results)
+	for _, record := range resp.Results {
+		result.assets = append(result.assets, AssetFromFofa(record))
+	}
+	return nil
+}
+
+func (a *Aggregator) searchZoomEye(ctx context.Context, result *EngineResult, size int) error {
+	resp, err := a.ZoomEye.Search(ctx, zoomeye.SearchParams{
+		QBase64:  a.ZoomEye.EncodeQuery(result.Query),
+		PageSize: size,
+		Fields:   ZoomEyeFields,
+	})
+	if err != nil {
+		return err
+	}
+
+	result.Total = resp.Total
+	result.Returned = len(resp.Data)
+	for _, data := range resp.Data {
+		result.assets = append(result.assets, AssetFromZoomEye(data))
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package src
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	fofa "fofa-mcp/src"
+	zoomeye "zoomeye-mcp/src"
+)
+
+// 各引擎收到的查询语句
+type sentQueries struct {
+	fofa, zoomEye string
+}
+
+// 模拟 FOFA 和 ZoomEye 搜索接口，记录收到的查询语句
+func newFakeAggregator(t *testing.T, queries *sentQueries) *Aggregator {
+	t.Helper()
+
+	fofaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query, _ := base64.StdEncoding.DecodeString(r.URL.Query().Get("qbase64"))
+		queries.fofa = string(query)
+		rows := map[string][]string{
+			"ip":    {"1.2.3.4", "5.6.7.8"},
+			"port":  {"443", "80"},
+			"title": {"Login", ""},
+		}
+		fields := strings.Split(r.URL.Query().Get("fields"), ",")
+		results := make([][]string, 2)
+		for i := range results {
+			for _, field := range fields {
+				value := ""
+				if column, ok := rows[field]; ok {
+					value = column[i]
+				}
+				results[i] = append(results[i], value)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"size": 2, "page": 1, "results": results})
+	}))
+	t.Cleanup(fofaServer.Close)
+
+	zoomEyeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			QBase64 string `json:"qbase64"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		query, _ := base64.StdEncoding.DecodeString(body.QBase64)
+		queries.zoomEye = string(query)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"code":  60000,
+			"total": 2,
+			"data": []map[string]interface{}{
+				{"ip": "1.2.3.4", "port": 443, "header.server.name": "nginx"},
+				{"ip": "9.9.9.9", "port": 22},
+			},
+		})
+	}))
+	t.Cleanup(zoomEyeServer.Close)
+
+	tier := fofa.TierEnterprise
+	fofaClient, err := fofa.NewFofaClientWithConfig("user@example.com", "key", fofa.ClientConfig{BaseURL: fofaServer.URL, AccountTier: &tier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zoomEyeClient, err := zoomeye.NewZoomEyeClientWithConfig("key", zoomeye.ClientConfig{BaseURL: zoomEyeServer.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Aggregator{Fofa: fofaClient, ZoomEye: zoomEyeClient}
+}
+
+func TestAggregatorSearch(t *testing.T) {
+	queries := &sentQueries{}
+	aggregator := newFakeAggregator(t, queries)
+
+	result, err := aggregator.Search(context.Background(), SearchParams{Query: `header="nginx" && icp="京ICP备"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if queries.fofa != `header="nginx" && icp="京ICP备"` || queries.zoomEye != `http.header="nginx"` {
+		t.Errorf("发送的查询语句为 %+v", *queries)
+	}
+	if len(result.Engines) != 2 || result.Engines[1].Returned != 2 || len(result.Engines[1].Warnings) == 0 {
+		t.Errorf("引擎查询情况为 %+v", result.Engines)
+	}
+
+	var keys []string
+	for _, asset := range result.Assets {
+		keys = append(keys, asset.Key()+"/"+strings.Join(asset.Engines, "+"))
+	}
+	want := "1.2.3.4:443/fofa+zoomeye 5.6.7.8:80/fofa 9.9.9.9:22/zoomeye"
+	if got := strings.Join(keys, " "); got != want {
+		t.Errorf("合并结果为 %s，期望 %s", got, want)
+	}
+	if first := result.Assets[0]; first.Title != "Login" || first.Server != "nginx" {
+		t.Errorf("第一条资产为 %+v", first)
+	}
+}
+
+func TestAggregatorPartialFailure(t *testing.T) {
+	queries := &sentQueries{}
+	aggregator := newFakeAggregator(t, queries)
+
+	// icp 无法翻译为 ZoomEye 语法，只查询 FOFA
+	result, err := aggregator.Search(context.Background(), SearchParams{Query: `icp="京ICP备"`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if queries.zoomEye != "" || result.Engines[1].Error == "" || len(result.Assets) != 2 {
+		t.Errorf("查询结果为 %+v", result)
+	}
+
+	if _, err := aggregator.Search(context.Background(), SearchParams{Query: `icp="a"`, Engines: []string{"zoomeye"}}); err == nil {
+		t.Error("所有引擎失败时应返回错误")
+	}
+	if _, err := aggregator.Search(context.Background(), SearchParams{Query: `app="a" AND port="80"`}); err == nil {
+		t.Error("语法错误的查询应直接返回错误")
+	}
+}
@@ -0,0 +1,215 @@
+package src
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	fofa "fofa-mcp/src"
+)
+
+// 搜索引擎名称
+const (
+	EngineFofa    = "fofa"
+	EngineZoomEye = "zoomeye"
+)
+
+// 各引擎返回的资产统一转换后的模型，同一 ip:port 只保留一条
+type Asset struct {
+	IP          string     `json:"ip"`
+	Port        int        `json:"port"`
+	Protocol    string     `json:"protocol,omitempty"`
+	Domain      string     `json:"domain,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Server      string     `json:"server,omitempty"`
+	Country     string     `json:"country,omitempty"`
+	ASN         int        `json:"asn,omitempty"`
+	CertSubject string     `json:"cert_subject,omitempty"`
+	LastSeen    *time.Time `json:"last_seen,omitempty"`
+	Engines     []string   `json:"engines"` // 发现该资产的引擎
+}
+
+// 去重键
+func (a *Asset) Key() string {
+	return fmt.Sprintf("%s:%d", a.IP, a.Port)
+}
+
+// 请求 FOFA 时使用的字段
+const FofaFields = "ip,port,protocol,domain,title,server,country_name,asn,cert.subject.cn,lastupdatetime"
+
+// 请求 ZoomEye 时使用的字段
+const ZoomEyeFields = "ip,port,service,domain,hostname,title,header.server.name,country.name,asn,ssl,update_time"
+
+// 将 FOFA 结果转换为资产，record 中的字段由 FofaFields 决定
+func AssetFromFofa(record fofa.Record) Asset {
+	asset := Asset{
+		IP:          stringValue(record["ip"]),
+		Port:        intValue(record["port"]),
+		Protocol:    stringValue(record["protocol"]),
+		Domain:      stringValue(record["domain"]),
+		Title:       stringValue(record["title"]),
+		Server:      stringValue(record["server"]),
+		Country:     stringValue(record["country_name"]),
+		ASN:         intValue(record["asn"]),
+		CertSubject: stringValue(record["cert.subject.cn"]),
+		Engines:     []string{EngineFofa},
+	}
+	if t, ok := record["lastupdatetime"].(time.Time); ok {
+		asset.LastSeen = &t
+	}
+	return asset
+}
+
+// 将 ZoomEye 结果转换为资产。ZoomEye 的嵌套字段可能以 "country.name" 形式的键
+// 或嵌套对象返回，title 可能为字符串数组，两种形式都能处理
+func AssetFromZoomEye(data map[string]interface{}) Asset {
+	asset := Asset{
+		IP:          stringValue(lookup(data, "ip")),
+		Port:        intValue(lookup(data, "port")),
+		Protocol:    stringValue(lookup(data, "service")),
+		Domain:      stringValue(lookup(data, "domain")),
+		Title:       stringValue(lookup(data, "title")),
+		Server:      stringValue(lookup(data, "header.server.name")),
+		Country:     stringValue(lookup(data, "country.name")),
+		ASN:         intValue(lookup(data, "asn")),
+		CertSubject: certSubject(stringValue(lookup(data, "ssl"))),
+		Engines:     []string{EngineZoomEye},
+	}
+	if asset.Domain == "" {
+		asset.Domain = stringValue(lookup(data, "hostname"))
+	}
+	if t, ok := parseTime(stringValue(lookup(data, "update_time"))); ok {
+		asset.LastSeen = &t
+	}
+	return asset
+}
+
+// 合并同一 ip:port 的资产：保留已有字段，缺失的字段由 other 补充，
+// 最后发现时间取较晚者，并记录所有发现该资产的引擎
+func (a *Asset) Merge(other Asset) {
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&a.Protocol, other.Protocol)
+	fill(&a.Domain, other.Domain)
+	fill(&a.Title, other.Title)
+	fill(&a.Server, other.Server)
+	fill(&a.Country, other.Country)
+	fill(&a.CertSubject, other.CertSubject)
+	if a.ASN == 0 {
+		a.ASN = other.ASN
+	}
+	if other.LastSeen != nil && (a.LastSeen == nil || other.LastSeen.After(*a.LastSeen)) {
+		a.LastSeen = other.LastSeen
+	}
+	for _, engine := range other.Engines {
+		if !containsString(a.Engines, engine) {
+			a.Engines = append(a.Engines, engine)
+		}
+	}
+}
+
+// 按 ip:port 去重，保持首次出现的顺序；缺少 ip 的结果被丢弃
+func MergeAssets(groups ...[]Asset) []Asset {
+	merged := []Asset{}
+	index := make(map[string]int)
+	for _, assets := range groups {
+		for _, asset := range assets {
+			if asset.IP == "" {
+				continue
+			}
+			key := asset.Key()
+			if i, ok := index[key]; ok {
+				merged[i].Merge(asset)
+				continue
+			}
+			index[key] = len(merged)
+			merged = append(merged, asset)
+		}
+	}
+	return merged
+}
+
+// 按点分路径取值，先尝试完整的键，再逐级查找嵌套对象
+func lookup(data map[string]interface{}, path string) interface{} {
+	if v, ok := data[path]; ok {
+		return v
+	}
+	head, rest, found := strings.Cut(path, ".")
+	if !found {
+		return nil
+	}
+	if nested, ok := data[head].(map[string]interface{}); ok {
+		return lookup(nested, rest)
+	}
+	return nil
+}
+
+// 转换为字符串，数组取第一个非空元素
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case int:
+		return strconv.Itoa(val)
+	case []interface{}:
+		for _, item := range val {
+			if s := stringValue(item); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// 转换为整数，支持 "AS4134" 形式的 ASN
+func intValue(v interface{}) int {
+	switch val := v.(type) {
+	case int:
+		return val
+	case float64:
+		return int(val)
+	case string:
+		s := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(val)), "AS")
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return 0
+}
+
+// ZoomEye 的时间不带时区，按 UTC 处理
+var timeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+var subjectCNRe = regexp.MustCompile(`(?m)^\s*Subject:.*?\bCN=([^,\n]+)`)
+
+// 从 ZoomEye 的 ssl 文本中提取证书主题的 CN
+func certSubject(ssl string) string {
+	if m := subjectCNRe.FindStringSubmatch(ssl); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return ""
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
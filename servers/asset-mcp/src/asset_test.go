@@ -0,0 +1,62 @@
+package src
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	fofa "fofa-mcp/src"
+)
+
+func TestAssetFromZoomEye(t *testing.T) {
+	asset := AssetFromZoomEye(map[string]interface{}{
+		"ip":                 "1.2.3.4",
+		"port":               float64(443),
+		"service":            "https",
+		"hostname":           "a.example.com",
+		"title":              []interface{}{"", "Login"},
+		"header.server.name": "nginx",
+		"country":            map[string]interface{}{"name": "China"},
+		"asn":                "AS4134",
+		"ssl":                "SSL Certificate\nVersion: TLS 1.2\nSubject: C=CN, CN=a.example.com\nIssuer: CN=R3",
+		"update_time":        "2024-05-20T12:34:56",
+	})
+	want := Asset{
+		IP: "1.2.3.4", Port: 443, Protocol: "https", Domain: "a.example.com", Title: "Login", Server: "nginx",
+		Country: "China", ASN: 4134, CertSubject: "a.example.com", Engines: []string{EngineZoomEye},
+	}
+	if asset.LastSeen == nil || !asset.LastSeen.Equal(time.Date(2024, 5, 20, 12, 34, 56, 0, time.UTC)) {
+		t.Errorf("last_seen 为 %v", asset.LastSeen)
+	}
+	asset.LastSeen = nil
+	if !reflect.DeepEqual(asset, want) {
+		t.Errorf("转换结果为 %+v，期望 %+v", asset, want)
+	}
+}
+
+func TestMergeAssets(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	fofaAssets := []Asset{
+		AssetFromFofa(fofa.Record{"ip": "1.2.3.4", "port": 443, "title": "Login", "asn": "4134", "lastupdatetime": older}),
+		AssetFromFofa(fofa.Record{"ip": "5.6.7.8", "port": 80}),
+		AssetFromFofa(fofa.Record{"ip": nil, "port": 80}),
+	}
+	zoomEyeAssets := []Asset{
+		{IP: "1.2.3.4", Port: 443, Title: "Other", Server: "nginx", LastSeen: &newer, Engines: []string{EngineZoomEye}},
+		{IP: "1.2.3.4", Port: 8443, Engines: []string{EngineZoomEye}},
+	}
+
+	merged := MergeAssets(fofaAssets, zoomEyeAssets)
+	if len(merged) != 3 {
+		t.Fatalf("合并后为 %d 条，期望 3 条: %+v", len(merged), merged)
+	}
+	first := merged[0]
+	if first.Key() != "1.2.3.4:443" || first.Title != "Login" || first.Server != "nginx" || first.ASN != 4134 ||
+		!first.LastSeen.Equal(newer) || !reflect.DeepEqual(first.Engines, []string{EngineFofa, EngineZoomEye}) {
+		t.Errorf("合并结果为 %+v", first)
+	}
+	if merged[1].Key() != "5.6.7.8:80" || merged[2].Key() != "1.2.3.4:8443" {
+		t.Errorf("合并后的顺序为 %s, %s", merged[1].Key(), merged[2].Key())
+	}
+}
@@ -0,0 +1,100 @@
+package src
+
+import (
+	"fmt"
+
+	fofa "fofa-mcp/src"
+	"securitymcp-hub/pkg/config"
+	"securitymcp-hub/pkg/secrets"
+	zoomeye "zoomeye-mcp/src"
+)
+
+// 服务配置，对应 config.yaml。fofa 和 zoomeye 配置项与各自的服务相同，
+// 同样可以通过 FOFA_*、ZOOMEYE_* 环境变量覆盖
+type Config struct {
+	Fofa    fofa.FofaConfig       `yaml:"fofa"`
+	ZoomEye zoomeye.ZoomEyeConfig `yaml:"zoomeye"`
+	Server  ServerConfig          `yaml:"server"`
+	Secrets SecretsConfig         `yaml:"secrets"`
+}
+
+// MCP 服务标识
+type ServerConfig struct {
+	Name    string `yaml:"name" env:"ASSET_MCP_NAME"`
+	Version string `yaml:"version" env:"ASSET_MCP_VERSION"`
+}
+
+// 凭证来源，两个引擎的 API Key 都从这里读取，含义与 fofa-mcp 相同
+type SecretsConfig struct {
+	File           string `yaml:"file" env:"ASSET_MCP_SECRETS_FILE"`
+	PassphraseFile string `yaml:"passphrase_file" env:"ASSET_MCP_SECRETS_PASSPHRASE_FILE"`
+	Exec           string `yaml:"exec" env:"ASSET_MCP_SECRETS_EXEC"`
+	ExecNotFound   int    `yaml:"exec_not_found_code" env:"ASSET_MCP_SECRETS_EXEC_NOT_FOUND_CODE"`
+}
+
+// 凭证来源的选项
+func (s SecretsConfig) Options() secrets.Options {
+	return secrets.Options{File: s.File, PassphraseFile: s.PassphraseFile, Exec: s.Exec, ExecNotFound: s.ExecNotFound}
+}
+
+// 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Fofa:    fofa.DefaultConfig().Fofa,
+		ZoomEye: zoomeye.DefaultConfig().ZoomEye,
+		Server: ServerConfig{
+			Name:    "asset-mcp",
+			Version: "1.0.0",
+		},
+	}
+}
+
+// 加载配置：默认值 < 配置文件 < 环境变量，path 为空时不读取配置文件
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if err := config.Load(path, cfg); err != nil {
+		return nil, err
+	}
+	if err := config.ApplyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 校验配置，fofa 和 zoomeye 部分沿用各自服务的校验规则
+func (c *Config) Validate() error {
+	if err := c.fofaConfig().Validate(); err != nil {
+		return err
+	}
+	if err := c.zoomEyeConfig().Validate(); err != nil {
+		return err
+	}
+	if c.Server.Name == "" {
+		return fmt.Errorf("配置项 server.name 不能为空")
+	}
+	if c.Server.Version == "" {
+		return fmt.Errorf("配置项 server.version 不能为空")
+	}
+	return nil
+}
+
+// FOFA 客户端配置，调用前应已通过 Validate 校验
+func (c *Config) FofaClientConfig() fofa.ClientConfig {
+	return c.fofaConfig().ClientConfig()
+}
+
+// ZoomEye 客户端配置
+func (c *Config) ZoomEyeClientConfig() zoomeye.ClientConfig {
+	return c.zoomEyeConfig().ClientConfig()
+}
+
+func (c *Config) fofaConfig() *fofa.Config {
+	return &fofa.Config{Fofa: c.Fofa, Server: fofa.ServerConfig(c.Server)}
+}
+
+func (c *Config) zoomEyeConfig() *zoomeye.Config {
+	return &zoomeye.Config{ZoomEye: c.ZoomEye, Server: zoomeye.ServerConfig(c.Server)}
+}
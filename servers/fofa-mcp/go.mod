@@ -2,3 +2,8 @@ module fofa-mcp
 
 go 1.21
 
+require securitymcp-hub/pkg v0.0.0
+
+require gopkg.in/yaml.v3 v3.0.1 // indirect
+
+replace securitymcp-hub/pkg => ../../pkg
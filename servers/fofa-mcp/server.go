@@ -1,125 +1,114 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	"fofa-mcp/src"
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/dork"
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/mcp"
+	"securitymcp-hub/pkg/redact"
+	"securitymcp-hub/pkg/secrets"
 )
 
-// MCP请求结构
-type MCPRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	ID      interface{}     `json:"id"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params,omitempty"`
-}
+func main() {
+	// 日志中的凭证替换为 [REDACTED]
+	log.SetOutput(redact.Writer(os.Stderr))
 
-// MCP响应结构
-type MCPResponse struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id"`
-	Result  interface{} `json:"result,omitempty"`
-	Error   *MCPError   `json:"error,omitempty"`
-}
+	flags := mcp.RegisterFlags(flag.CommandLine)
+	configPath := flag.String("config", os.Getenv("FOFA_MCP_CONFIG"), "配置文件路径（YAML），也可通过环境变量 FOFA_MCP_CONFIG 设置")
+	flag.Parse()
 
-type MCPError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
+	cfg, err := src.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
 
-// 工具定义
-type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	InputSchema map[string]interface{} `json:"inputSchema"`
-}
+	// 获取FOFA凭证
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(keys) == 0 {
+		log.Fatal("请设置环境变量 FOFA_EMAIL 和 FOFA_KEY，或通过 FOFA_KEYS 设置多个账号；也可以使用 _FILE 文件、加密凭证文件或辅助命令")
+	}
 
-// 调用工具请求
-type CallToolRequest struct {
-	Name      string                 `json:"name"`
-	Arguments map[string]interface{} `json:"arguments"`
-}
+	// 创建FOFA客户端
+	fofaClient, err := src.NewFofaClientWithKeys(keys, cfg.ClientConfig())
+	if err != nil {
+		log.Fatalf("创建FOFA客户端失败: %v", err)
+	}
 
-// 调用工具结果
-type CallToolResult struct {
-	Content []map[string]interface{} `json:"content"`
-	IsError bool                     `json:"isError,omitempty"`
-}
+	// 收到 SIGHUP 时重新读取凭证，失败时继续使用原有凭证
+	secrets.OnReload(func() {
+		keys, err := loadKeys(cfg)
+		if err == nil {
+			err = fofaClient.Keys.Replace(keys)
+		}
+		if err != nil {
+			log.Printf("重新加载FOFA凭证失败，继续使用原有凭证: %v", err)
+			return
+		}
+		log.Printf("已重新加载 %d 个FOFA API Key", len(keys))
+	})
 
-func main() {
-	// 从环境变量获取FOFA凭证
-	email := os.Getenv("FOFA_EMAIL")
-	key := os.Getenv("FOFA_KEY")
+	server := mcp.NewServer(cfg.Server.Name, cfg.Server.Version)
+	registerTools(server, fofaClient)
 
-	if email == "" || key == "" {
-		log.Fatal("请设置环境变量 FOFA_EMAIL 和 FOFA_KEY")
+	if err := server.Run(flags); err != nil {
+		log.Fatal(err)
 	}
+}
 
-	// 创建FOFA客户端
-	fofaClient := src.NewFofaClient(email, key)
+// 按配置的凭证来源读取 API Key
+func loadKeys(cfg *src.Config) ([]keypool.Key, error) {
+	store, err := secrets.Open(cfg.Secrets.Options())
+	if err != nil {
+		return nil, err
+	}
+	return src.LoadKeys(context.Background(), store)
+}
 
-	// 使用标准输入输出进行JSON-RPC通信
-	scanner := bufio.NewScanner(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+// 注册FOFA工具
+func registerTools(server *mcp.Server, client *src.FofaClient) {
+	server.RegisterTool("fofa_search", `在FOFA中搜索资产。支持自定义查询语句、分页、返回字段等所有参数。所有参数都可以由大模型自主配置，包括查询语句、页码、每页数量、返回字段等。
 
-	for scanner.Scan() {
-		var request MCPRequest
-		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
-			sendError(encoder, nil, -32700, "Parse error", err.Error())
-			continue
-		}
+支持50个返回字段，包括基础字段（ip,port,host等）、地理位置字段（country,region,city等）、证书字段（cert.*）、协议字段（banner,protocol等）、产品字段（product,product.version等）等。字段权限取决于FOFA账号版本。
 
-		var response MCPResponse
-		response.JSONRPC = "2.0"
-		response.ID = request.ID
+重要限制：当fields参数包含cert或banner字段时，size参数最大值自动限制为2000（而非10000）。size超过上限时会被调整，并在结果的warnings中说明。
 
-		switch request.Method {
-		case "initialize":
-			response.Result = map[string]interface{}{
-				"protocolVersion": "2024-11-05",
-				"capabilities": map[string]interface{}{
-					"tools": map[string]interface{}{},
+设置max_results时会从page开始自动翻页，直到取满max_results条或没有更多结果，并在结果中报告请求的页数和消耗的F点。`,
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "FOFA查询语句，例如：app=\"Apache\" && country=\"CN\"。可以根据需要构建任意查询语句",
 				},
-				"serverInfo": map[string]interface{}{
-					"name":    "fofa-mcp",
-					"version": "1.0.0",
+				"page": map[string]interface{}{
+					"type":        "integer",
+					"description": "页码，从1开始，默认为1。可以根据需要设置任意页码进行翻页",
+					"default":     1,
 				},
-			}
-
-		case "tools/list":
-			response.Result = map[string]interface{}{
-				"tools": []Tool{
-					{
-						Name: "fofa_search",
-						Description: `在FOFA中搜索资产。支持自定义查询语句、分页、返回字段等所有参数。所有参数都可以由大模型自主配置，包括查询语句、页码、每页数量、返回字段等。
-
-支持50个返回字段，包括基础字段（ip,port,host等）、地理位置字段（country,region,city等）、证书字段（cert.*）、协议字段（banner,protocol等）、产品字段（product,product.version等）等。字段权限取决于FOFA账号版本。
-
-重要限制：当fields参数包含cert或banner字段时，size参数最大值自动限制为2000（而非10000）。`,
-						InputSchema: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"query": map[string]interface{}{
-									"type":        "string",
-									"description": "FOFA查询语句，例如：app=\"Apache\" && country=\"CN\"。可以根据需要构建任意查询语句",
-								},
-								"page": map[string]interface{}{
-									"type":        "integer",
-									"description": "页码，从1开始，默认为1。可以根据需要设置任意页码进行翻页",
-									"default":     1,
-								},
-								"size": map[string]interface{}{
-									"type":        "integer",
-									"description": "每页返回数量，范围1-10000，默认为100。可以根据需要设置任意数量。重要限制：当fields参数包含cert或banner字段时，size最大值限制为2000",
-									"default":     100,
-								},
-								"fields": map[string]interface{}{
-									"type": "string",
-									"description": `返回字段，逗号分隔，例如：host,ip,port,protocol,title。支持所有FOFA API字段，可根据需要选择任意字段组合。
+				"size": map[string]interface{}{
+					"type":        "integer",
+					"description": "每页返回数量，范围1-10000，默认为100。可以根据需要设置任意数量。重要限制：当fields参数包含cert或banner字段时，size最大值限制为2000",
+					"default":     100,
+				},
+				"max_results": map[string]interface{}{
+					"type":        "integer",
+					"description": "最多返回的结果数量。设置后自动连续请求多页，无需手动修改page翻页；未设置size时每页数量取max_results与单页上限中的较小值。每页都会计费，请按需设置",
+					"minimum":     1,
+				},
+				"fields": map[string]interface{}{
+					"type": "string",
+					"description": `返回字段，逗号分隔，例如：host,ip,port,protocol,title。支持所有FOFA API字段，可根据需要选择任意字段组合。
 
 完整字段列表（共50个字段）：
 【无权限字段（1-33）】：ip,port,protocol,country,country_name,region,city,longitude,latitude,asn,org,host,domain,os,server,icp,title,jarm,header,banner,cert,base_protocol,link,cert.issuer.org,cert.issuer.cn,cert.subject.org,cert.subject.cn,tls.ja3s,tls.version,cert.sn,cert.not_before,cert.not_after,cert.domain
@@ -130,142 +119,335 @@ func main() {
 
 重要提示：
 - 当查询包含cert或banner字段时，size参数值最大为2000
-- 字段权限取决于您的FOFA账号版本，超出权限的字段将返回空值
+- 字段权限取决于您的FOFA账号版本，超出权限的字段会在结果的warnings中提示（服务端可配置为直接移除），否则将返回空值
+- 不在以上列表中的字段会被拒绝，错误信息中会给出相近的字段名
 - 可以根据实际需求灵活组合任意字段`,
-									"default": "host,ip,port,protocol",
-								},
-								"full": map[string]interface{}{
-									"type":        "boolean",
-									"description": "是否返回全量数据，默认为false",
-									"default":     false,
-								},
-								"is_domain": map[string]interface{}{
-									"type":        "boolean",
-									"description": "是否为域名查询，默认为false",
-									"default":     false,
-								},
-							},
-							"required": []string{"query"},
-						},
-					},
-					{
-						Name:        "fofa_stats",
-						Description: "获取FOFA查询结果的统计信息。支持自定义查询语句和统计字段。",
-						InputSchema: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"query": map[string]interface{}{
-									"type":        "string",
-									"description": "FOFA查询语句",
-								},
-								"fields": map[string]interface{}{
-									"type":        "string",
-									"description": "要统计的字段，逗号分隔，例如：country,server,protocol。可以根据需要选择任意字段进行统计",
-								},
-							},
-							"required": []string{"query"},
-						},
-					},
-					{
-						Name:        "fofa_host_info",
-						Description: "获取指定主机的详细信息，包括IP、ASN、组织、国家、协议等。",
-						InputSchema: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"host": map[string]interface{}{
-									"type":        "string",
-									"description": "主机地址，可以是IP或域名",
-								},
-							},
-							"required": []string{"host"},
-						},
-					},
+					"default": src.DefaultFields,
 				},
-			}
+				"full": map[string]interface{}{
+					"type":        "boolean",
+					"description": "是否返回全量数据，默认为false",
+					"default":     false,
+				},
+				"is_domain": map[string]interface{}{
+					"type":        "boolean",
+					"description": "是否为域名查询，默认为false",
+					"default":     false,
+				},
+				"cache": cache.ArgumentSchema,
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleFofaSearch(ctx, client, args)
+		},
+		mcp.WithOutputSchema(fofaSearchOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "FOFA 资产搜索", ReadOnlyHint: true, OpenWorldHint: true}))
+
+	server.RegisterTool("fofa_search_next", `通过FOFA连续翻页接口（search/next）获取大量结果。首次调用不传next，之后每次把上一次返回的next原样传回即可获取下一页，直到has_more为false。
+
+与fofa_search的page翻页相比，游标翻页不受深度翻页限制，翻页过程中结果集保持一致，不会出现重复或遗漏的行。每次翻页时query、fields、size应与首次调用保持一致。`,
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "FOFA查询语句，翻页时必须与首次调用相同",
+				},
+				"next": map[string]interface{}{
+					"type":        "string",
+					"description": "上一次调用返回的next游标，首次调用不传",
+				},
+				"size": map[string]interface{}{
+					"type":        "integer",
+					"description": "每页返回数量，范围1-10000，默认为100。当fields参数包含cert或banner字段时，size最大值限制为2000",
+					"default":     100,
+				},
+				"fields": map[string]interface{}{
+					"type":        "string",
+					"description": "返回字段，逗号分隔，可选字段与fofa_search相同",
+					"default":     src.DefaultFields,
+				},
+				"full": map[string]interface{}{
+					"type":        "boolean",
+					"description": "是否返回全量数据，默认为false",
+					"default":     false,
+				},
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleFofaSearchNext(ctx, client, args)
+		},
+		mcp.WithOutputSchema(fofaSearchNextOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "FOFA 连续翻页", ReadOnlyHint: true, OpenWorldHint: true}))
 
-		case "tools/call":
-			var callRequest struct {
-				Name      string                 `json:"name"`
-				Arguments map[string]interface{} `json:"arguments"`
-			}
-			if err := json.Unmarshal(request.Params, &callRequest); err != nil {
-				sendError(encoder, request.ID, -32602, "Invalid params", err.Error())
-				continue
-			}
+	server.RegisterTool("fofa_account_info", `获取当前FOFA账号信息，包括会员等级、F点余额、剩余免费点数、当月剩余查询次数和可返回数据条数。
 
-			var result CallToolResult
-			var err error
-
-			switch callRequest.Name {
-			case "fofa_search":
-				result, err = handleFofaSearch(fofaClient, callRequest.Arguments)
-			case "fofa_stats":
-				result, err = handleFofaStats(fofaClient, callRequest.Arguments)
-			case "fofa_host_info":
-				result, err = handleFofaHostInfo(fofaClient, callRequest.Arguments)
-			default:
-				sendError(encoder, request.ID, -32601, "Method not found", fmt.Sprintf("Unknown tool: %s", callRequest.Name))
-				continue
-			}
+可在执行大量查询前确认剩余额度，或根据会员等级选择可用的返回字段。`,
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleFofaAccountInfo(ctx, client)
+		},
+		mcp.WithOutputSchema(fofaAccountInfoOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "FOFA 账号信息", ReadOnlyHint: true, OpenWorldHint: true}))
 
-			if err != nil {
-				result = CallToolResult{
-					Content: []map[string]interface{}{
-						{
-							"type": "text",
-							"text": fmt.Sprintf("错误: %v", err),
-						},
-					},
-					IsError: true,
-				}
-			}
+	server.RegisterTool("fofa_query_validate", `检查FOFA查询语句的语法，不会调用FOFA API，也不消耗F点。
 
-			response.Result = result
+支持的语法：key="value"、key=="value"（精确匹配）、key!="value"（排除）、key*="value"（模糊匹配）、&&、||、括号，以及单独的"关键词"全文搜索。
 
-		default:
-			sendError(encoder, request.ID, -32601, "Method not found", fmt.Sprintf("Unknown method: %s", request.Method))
-			continue
-		}
+语法正确时返回规范化的查询语句和语法树，并提示值格式可能不正确的条件；语法错误时返回出错位置和原因，例如未闭合的引号、使用AND代替&&、未知的查询字段等。fofa_search在调用API前会进行同样的检查。`,
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "要检查的FOFA查询语句",
+				},
+			},
+			"required": []string{"query"},
+		},
+		dork.ValidateHandler(dork.FOFA),
+		mcp.WithOutputSchema(dork.ValidateOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "FOFA 查询语法检查", ReadOnlyHint: true, IdempotentHint: true}))
+
+	dork.RegisterTranslateTool(server, dork.FOFA)
+
+	server.RegisterTool("fofa_stats", "获取FOFA查询结果的统计信息。支持自定义查询语句和统计字段。",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "FOFA查询语句",
+				},
+				"fields": map[string]interface{}{
+					"type":        "string",
+					"description": "要统计的字段，逗号分隔，例如：country,server,protocol。可以根据需要选择任意字段进行统计",
+				},
+				"cache": cache.ArgumentSchema,
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleFofaStats(ctx, client, args)
+		},
+		mcp.WithOutputSchema(fofaStatsOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "FOFA 统计聚合", ReadOnlyHint: true, OpenWorldHint: true}))
+
+	server.RegisterTool("fofa_host_info", "获取指定主机的详细信息，包括IP、ASN、组织、国家、协议等。",
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"host": map[string]interface{}{
+					"type":        "string",
+					"description": "主机地址，可以是IP或域名",
+				},
+				"cache": cache.ArgumentSchema,
+			},
+			"required": []string{"host"},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleFofaHostInfo(ctx, client, args)
+		},
+		mcp.WithOutputSchema(fofaHostInfoOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "FOFA 主机信息", ReadOnlyHint: true, OpenWorldHint: true}))
+}
 
-		if err := encoder.Encode(response); err != nil {
-			log.Printf("编码响应失败: %v", err)
-		}
-	}
+// 搜索结果记录，以请求的字段名为键
+var fofaRecordsSchema = map[string]interface{}{
+	"type":        "array",
+	"description": "结果记录，以 fields 参数中的字段名为键。port 为整数，latitude/longitude 为数字，lastupdatetime、cert.not_before、cert.not_after 为 RFC 3339 时间（北京时间），空值为 null，其余字段为字符串",
+	"items": map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": true,
+	},
+}
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
-	}
+// 提示信息，例如超出会员等级的字段
+var fofaWarningsSchema = map[string]interface{}{
+	"type":        "array",
+	"description": "提示信息，例如请求的字段超出账号会员等级、size 超过单页上限被调整",
+	"items":       map[string]interface{}{"type": "string"},
+}
+
+// fofa_search 结构化输出
+type fofaSearchOutput struct {
+	Success         bool            `json:"success"`
+	Query           string          `json:"query"`
+	Page            int             `json:"page"`
+	Size            int             `json:"size"`
+	Mode            string          `json:"mode"`
+	Total           int             `json:"total"`
+	Pages           int             `json:"pages"`
+	ConsumedFpoints int             `json:"consumed_fpoints"`
+	HasMore         bool            `json:"has_more"`
+	Warnings        []string        `json:"warnings,omitempty"`
+	Cache           *cache.Result   `json:"cache"`
+	Budget          *budget.Report  `json:"budget,omitempty"`
+	Keys            *keypool.Result `json:"keys"`
+	Results         []src.Record    `json:"results"`
+}
+
+var fofaSearchOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success":          map[string]interface{}{"type": "boolean"},
+		"query":            map[string]interface{}{"type": "string", "description": "实际执行的查询语句"},
+		"page":             map[string]interface{}{"type": "integer", "description": "起始页码"},
+		"size":             map[string]interface{}{"type": "integer", "description": "查询结果总数"},
+		"mode":             map[string]interface{}{"type": "string"},
+		"total":            map[string]interface{}{"type": "integer", "description": "本次返回的结果数量"},
+		"pages":            map[string]interface{}{"type": "integer", "description": "本次请求的页数"},
+		"consumed_fpoints": map[string]interface{}{"type": "integer", "description": "本次所有请求消耗的F点合计"},
+		"has_more":         map[string]interface{}{"type": "boolean", "description": "是否还有未返回的结果"},
+		"warnings":         fofaWarningsSchema,
+		"cache":            cache.ResultSchema,
+		"budget":           budget.ResultSchema,
+		"keys":             keypool.ResultSchema,
+		"results":          fofaRecordsSchema,
+	},
+	"required": []string{"success", "query", "page", "size", "total", "pages", "consumed_fpoints", "has_more", "cache", "results"},
+}
+
+// fofa_search_next 结构化输出
+type fofaSearchNextOutput struct {
+	Success         bool            `json:"success"`
+	Query           string          `json:"query"`
+	Size            int             `json:"size"`
+	Total           int             `json:"total"`
+	ConsumedFpoints int             `json:"consumed_fpoints"`
+	Next            string          `json:"next"`
+	HasMore         bool            `json:"has_more"`
+	Warnings        []string        `json:"warnings,omitempty"`
+	Budget          *budget.Report  `json:"budget,omitempty"`
+	Keys            *keypool.Result `json:"keys"`
+	Results         []src.Record    `json:"results"`
 }
 
-func sendError(encoder *json.Encoder, id interface{}, code int, message, data string) {
-	response := MCPResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Error: &MCPError{
-			Code:    code,
-			Message: message,
+var fofaSearchNextOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success":          map[string]interface{}{"type": "boolean"},
+		"query":            map[string]interface{}{"type": "string", "description": "实际执行的查询语句"},
+		"size":             map[string]interface{}{"type": "integer", "description": "查询结果总数"},
+		"total":            map[string]interface{}{"type": "integer", "description": "本页返回的结果数量"},
+		"consumed_fpoints": map[string]interface{}{"type": "integer", "description": "本次请求消耗的F点"},
+		"next":             map[string]interface{}{"type": "string", "description": "下一页游标，继续翻页时原样传回"},
+		"has_more":         map[string]interface{}{"type": "boolean", "description": "是否还有下一页"},
+		"warnings":         fofaWarningsSchema,
+		"budget":           budget.ResultSchema,
+		"keys":             keypool.ResultSchema,
+		"results":          fofaRecordsSchema,
+	},
+	"required": []string{"success", "query", "size", "total", "next", "has_more", "results"},
+}
+
+// fofa_account_info 结构化输出
+type fofaAccountInfoOutput struct {
+	Success         bool   `json:"success"`
+	Email           string `json:"email"`
+	Username        string `json:"username"`
+	Category        string `json:"category"`
+	IsVIP           bool   `json:"isvip"`
+	VIPLevel        int    `json:"vip_level"`
+	Tier            string `json:"tier"`
+	FofaPoint       int    `json:"fofa_point"`
+	RemainFreePoint int    `json:"remain_free_point"`
+	RemainAPIQuery  int    `json:"remain_api_query"`
+	RemainAPIData   int    `json:"remain_api_data"`
+
+	Keys    *keypool.Result     `json:"keys"`
+	KeyPool []keypool.KeyStatus `json:"key_pool,omitempty"` // 配置了多个 API Key 时各 key 的状态
+}
+
+var fofaAccountInfoOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success":           map[string]interface{}{"type": "boolean"},
+		"email":             map[string]interface{}{"type": "string"},
+		"username":          map[string]interface{}{"type": "string"},
+		"category":          map[string]interface{}{"type": "string", "description": "账号类别"},
+		"isvip":             map[string]interface{}{"type": "boolean", "description": "是否为会员"},
+		"vip_level":         map[string]interface{}{"type": "integer", "description": "FOFA 返回的会员等级编号"},
+		"tier":              map[string]interface{}{"type": "string", "description": "会员等级名称，决定可用的返回字段"},
+		"fofa_point":        map[string]interface{}{"type": "integer", "description": "F点余额"},
+		"remain_free_point": map[string]interface{}{"type": "integer", "description": "剩余免费点数"},
+		"remain_api_query":  map[string]interface{}{"type": "integer", "description": "当月剩余查询次数"},
+		"remain_api_data":   map[string]interface{}{"type": "integer", "description": "当月剩余可返回数据条数"},
+		"keys":              keypool.ResultSchema,
+		"key_pool":          keypool.StatusSchema,
+	},
+	"required": []string{"success", "vip_level", "tier", "fofa_point", "remain_api_query", "remain_api_data"},
+}
+
+// fofa_stats 结构化输出
+type fofaStatsOutput struct {
+	Success  bool                   `json:"success"`
+	Distinct map[string]int         `json:"distinct"`
+	Aggs     map[string]interface{} `json:"aggs"`
+	Warnings []string               `json:"warnings,omitempty"`
+	Cache    *cache.Result          `json:"cache"`
+	Keys     *keypool.Result        `json:"keys"`
+}
+
+var fofaStatsOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success": map[string]interface{}{"type": "boolean"},
+		"distinct": map[string]interface{}{
+			"type":                 "object",
+			"description":          "各字段的去重数量",
+			"additionalProperties": map[string]interface{}{"type": "integer"},
 		},
-	}
-	if data != "" {
-		response.Error.Message = fmt.Sprintf("%s: %s", message, data)
-	}
-	encoder.Encode(response)
+		"aggs": map[string]interface{}{
+			"type":        "object",
+			"description": "各字段的聚合统计",
+		},
+		"warnings": fofaWarningsSchema,
+		"cache":    cache.ResultSchema,
+		"keys":     keypool.ResultSchema,
+	},
+	"required": []string{"success", "cache"},
+}
+
+// fofa_host_info 返回 API 的全部字段，只约束 success、cache 和 keys
+var fofaHostInfoOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success": map[string]interface{}{"type": "boolean"},
+		"cache":   cache.ResultSchema,
+		"keys":    keypool.ResultSchema,
+	},
+	"required":             []string{"success", "cache"},
+	"additionalProperties": true,
 }
 
-func handleFofaSearch(client *src.FofaClient, args map[string]interface{}) (CallToolResult, error) {
+func handleFofaSearch(ctx context.Context, client *src.FofaClient, args map[string]interface{}) (mcp.CallToolResult, error) {
 	query, ok := args["query"].(string)
 	if !ok || query == "" {
-		return CallToolResult{}, fmt.Errorf("query参数是必需的")
+		return mcp.CallToolResult{}, fmt.Errorf("query参数是必需的")
+	}
+	queryWarnings, err := dork.FOFA.Check(query)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
+	ctx, cacheStatus, err := cache.FromArgs(ctx, args)
+	if err != nil {
+		return mcp.CallToolResult{}, err
 	}
 
 	params := src.QueryParams{
 		Query:    query,
 		Page:     1,
-		Size:     100,
-		Fields:   "host,ip,port,protocol",
+		Fields:   src.DefaultFields,
 		Full:     false,
 		IsDomain: false,
 	}
+	maxResults := 0
 
 	// 解析可选参数
 	if page, ok := args["page"].(float64); ok {
@@ -274,6 +456,12 @@ func handleFofaSearch(client *src.FofaClient, args map[string]interface{}) (Call
 	if size, ok := args["size"].(float64); ok {
 		params.Size = int(size)
 	}
+	if n, ok := args["max_results"].(float64); ok {
+		if n < 1 {
+			return mcp.CallToolResult{}, fmt.Errorf("max_results参数必须大于0")
+		}
+		maxResults = int(n)
+	}
 	if fields, ok := args["fields"].(string); ok && fields != "" {
 		params.Fields = fields
 	}
@@ -284,100 +472,211 @@ func handleFofaSearch(client *src.FofaClient, args map[string]interface{}) (Call
 		params.IsDomain = isDomain
 	}
 
-	result, err := client.Search(params)
+	// 会员等级查询和搜索使用的 key 都记录在 keys 中
+	ctx, keyUsage := keypool.Track(ctx)
+	check, err := client.CheckFields(ctx, params.Fields)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
+	params.Fields = check.Fields.String()
+	warnings := append(queryWarnings, check.Warnings...)
+	if params.Size > 0 {
+		var notice string
+		if params.Size, notice = check.Fields.ClampSize(params.Size); notice != "" {
+			warnings = append(warnings, notice)
+		}
+	}
+
+	// 未设置 max_results 时只请求一页
+	result, err := client.SearchAll(ctx, params, maxResults)
 	if err != nil {
-		return CallToolResult{}, err
+		return mcp.CallToolResult{}, err
+	}
+	if result.Stopped != nil {
+		warnings = append(warnings, fmt.Sprintf("自动翻页提前结束，只返回了已取回的结果: %v", result.Stopped))
 	}
 
-	response := map[string]interface{}{
-		"success": true,
-		"query":   result.Query,
-		"page":    result.Page,
-		"size":    result.Size,
-		"mode":    result.Mode,
-		"total":   len(result.Results),
-		"results": result.Results,
+	return mcp.StructuredResult(fofaSearchOutput{
+		Success:         true,
+		Query:           result.Query,
+		Page:            result.StartPage,
+		Size:            result.Total,
+		Mode:            result.Mode,
+		Total:           len(result.Results),
+		Pages:           result.Pages,
+		ConsumedFpoints: result.ConsumedFpoint,
+		HasMore:         result.HasMore,
+		Warnings:        warnings,
+		Cache:           cacheStatus.Result(),
+		Budget:          client.Budget.Report(),
+		Keys:            keyUsage.Result(),
+		Results:         result.Results,
+	}), nil
+}
+
+func handleFofaAccountInfo(ctx context.Context, client *src.FofaClient) (mcp.CallToolResult, error) {
+	ctx, keyUsage := keypool.Track(ctx)
+	info, err := client.AccountInfo(ctx)
+	if err != nil {
+		return mcp.CallToolResult{}, err
 	}
 
-	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	tierName := "未知"
+	if tier, ok := info.Tier(); ok {
+		tierName = tier.String()
+	}
 
-	return CallToolResult{
-		Content: []map[string]interface{}{
-			{
-				"type": "text",
-				"text": string(responseJSON),
-			},
-		},
-	}, nil
+	output := fofaAccountInfoOutput{
+		Success:         true,
+		Email:           info.Email,
+		Username:        info.Username,
+		Category:        info.Category,
+		IsVIP:           info.IsVIP,
+		VIPLevel:        info.VIPLevel,
+		Tier:            tierName,
+		FofaPoint:       info.FofaPoint,
+		RemainFreePoint: info.RemainFreePoint,
+		RemainAPIQuery:  info.RemainAPIQuery,
+		RemainAPIData:   info.RemainAPIData,
+		Keys:            keyUsage.Result(),
+	}
+	if client.Keys.Len() > 1 {
+		output.KeyPool = client.Keys.Status()
+	}
+	return mcp.StructuredResult(output), nil
 }
 
-func handleFofaStats(client *src.FofaClient, args map[string]interface{}) (CallToolResult, error) {
+func handleFofaSearchNext(ctx context.Context, client *src.FofaClient, args map[string]interface{}) (mcp.CallToolResult, error) {
 	query, ok := args["query"].(string)
 	if !ok || query == "" {
-		return CallToolResult{}, fmt.Errorf("query参数是必需的")
+		return mcp.CallToolResult{}, fmt.Errorf("query参数是必需的")
+	}
+	queryWarnings, err := dork.FOFA.Check(query)
+	if err != nil {
+		return mcp.CallToolResult{}, err
 	}
 
-	fields := ""
-	if f, ok := args["fields"].(string); ok {
-		fields = f
+	params := src.SearchNextParams{
+		Query:  query,
+		Size:   100,
+		Fields: src.DefaultFields,
 	}
 
-	result, err := client.Stats(query, fields)
+	if next, ok := args["next"].(string); ok {
+		params.Next = next
+	}
+	if size, ok := args["size"].(float64); ok {
+		params.Size = int(size)
+	}
+	if fields, ok := args["fields"].(string); ok && fields != "" {
+		params.Fields = fields
+	}
+	if full, ok := args["full"].(bool); ok {
+		params.Full = full
+	}
+
+	// 会员等级查询和搜索使用的 key 都记录在 keys 中
+	ctx, keyUsage := keypool.Track(ctx)
+	check, err := client.CheckFields(ctx, params.Fields)
 	if err != nil {
-		return CallToolResult{}, err
+		return mcp.CallToolResult{}, err
+	}
+	params.Fields = check.Fields.String()
+	warnings := append(queryWarnings, check.Warnings...)
+	if params.Size > 0 {
+		var notice string
+		if params.Size, notice = check.Fields.ClampSize(params.Size); notice != "" {
+			warnings = append(warnings, notice)
+		}
 	}
 
-	response := map[string]interface{}{
-		"success":  true,
-		"distinct": result.Distinct,
-		"aggs":     result.Aggs,
+	result, err := client.SearchNext(ctx, params)
+	if err != nil {
+		return mcp.CallToolResult{}, err
 	}
 
-	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	return mcp.StructuredResult(fofaSearchNextOutput{
+		Success:         true,
+		Query:           result.Query,
+		Size:            result.Size,
+		Total:           len(result.Results),
+		ConsumedFpoints: result.ConsumedFpoint,
+		Next:            result.Next,
+		// 最后一页之后 FOFA 可能仍返回游标，但不再有结果
+		HasMore:  result.Next != "" && len(result.Results) > 0,
+		Warnings: warnings,
+		Budget:   client.Budget.Report(),
+		Keys:     keyUsage.Result(),
+		Results:  result.Results,
+	}), nil
+}
 
-	return CallToolResult{
-		Content: []map[string]interface{}{
-			{
-				"type": "text",
-				"text": string(responseJSON),
-			},
-		},
-	}, nil
+func handleFofaStats(ctx context.Context, client *src.FofaClient, args map[string]interface{}) (mcp.CallToolResult, error) {
+	query, ok := args["query"].(string)
+	if !ok || query == "" {
+		return mcp.CallToolResult{}, fmt.Errorf("query参数是必需的")
+	}
+	queryWarnings, err := dork.FOFA.Check(query)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
+	ctx, cacheStatus, err := cache.FromArgs(ctx, args)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
+
+	fields := ""
+	if f, ok := args["fields"].(string); ok {
+		fields = f
+	}
+
+	ctx, keyUsage := keypool.Track(ctx)
+	result, err := client.Stats(ctx, query, fields)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
+
+	return mcp.StructuredResult(fofaStatsOutput{
+		Success:  true,
+		Distinct: result.Distinct,
+		Aggs:     result.Aggs,
+		Warnings: queryWarnings,
+		Cache:    cacheStatus.Result(),
+		Keys:     keyUsage.Result(),
+	}), nil
 }
 
-func handleFofaHostInfo(client *src.FofaClient, args map[string]interface{}) (CallToolResult, error) {
+func handleFofaHostInfo(ctx context.Context, client *src.FofaClient, args map[string]interface{}) (mcp.CallToolResult, error) {
 	host, ok := args["host"].(string)
 	if !ok || host == "" {
-		return CallToolResult{}, fmt.Errorf("host参数是必需的")
+		return mcp.CallToolResult{}, fmt.Errorf("host参数是必需的")
+	}
+	ctx, cacheStatus, err := cache.FromArgs(ctx, args)
+	if err != nil {
+		return mcp.CallToolResult{}, err
 	}
 
-	result, err := client.GetHostInfo(host)
+	ctx, keyUsage := keypool.Track(ctx)
+	result, err := client.GetHostInfo(ctx, host)
 	if err != nil {
-		return CallToolResult{}, err
+		return mcp.CallToolResult{}, err
 	}
 
 	// 直接返回所有字段，不写死任何字段
 	response := map[string]interface{}{
 		"success": true,
+		"cache":   cacheStatus.Result(),
+		"keys":    keyUsage.Result(),
 	}
 	// 将 API 返回的所有字段都包含进来
 	if result != nil {
 		for k, v := range *result {
-			// 跳过 error 字段（已经处理过）
-			if k != "error" && k != "errmsg" {
+			// 跳过 error 字段（已经处理过）和与缓存、key 信息同名的字段
+			if k != "error" && k != "errmsg" && k != "cache" && k != "keys" {
 				response[k] = v
 			}
 		}
 	}
 
-	responseJSON, _ := json.MarshalIndent(response, "", "  ")
-
-	return CallToolResult{
-		Content: []map[string]interface{}{
-			{
-				"type": "text",
-				"text": string(responseJSON),
-			},
-		},
-	}, nil
+	return mcp.StructuredResult(response), nil
 }
@@ -0,0 +1,132 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/redact"
+)
+
+// 账号信息缓存时间，会员等级很少变化，避免每次搜索都查询一次
+const accountInfoTTL = 10 * time.Minute
+
+// 查询账号信息失败后的重试间隔
+const accountInfoRetryDelay = time.Minute
+
+// 账号信息响应
+type AccountInfo struct {
+	Error           bool   `json:"error"`
+	ErrMsg          string `json:"errmsg,omitempty"`
+	Email           string `json:"email"`
+	Username        string `json:"username"`
+	Category        string `json:"category"`
+	IsVIP           bool   `json:"isvip"`
+	VIPLevel        int    `json:"vip_level"`
+	FofaPoint       int    `json:"fofa_point"`        // F点余额
+	RemainFreePoint int    `json:"remain_free_point"` // 剩余免费点数
+	RemainAPIQuery  int    `json:"remain_api_query"`  // 当月剩余查询次数
+	RemainAPIData   int    `json:"remain_api_data"`   // 当月剩余可返回数据条数
+}
+
+// 根据 vip_level 返回会员等级，无法识别的等级返回 false
+func (a *AccountInfo) Tier() (AccountTier, bool) {
+	switch a.VIPLevel {
+	case 0:
+		return TierFree, true
+	case 1, 11: // 普通会员、个人版
+		return TierPersonal, true
+	case 2, 12: // 高级会员、专业版
+		return TierProfessional, true
+	case 13: // 商业版
+		return TierBusiness, true
+	case 3, 5: // 企业会员、企业版
+		return TierEnterprise, true
+	}
+	return 0, false
+}
+
+// 获取账号信息，包括会员等级、F点余额和剩余查询额度
+func (c *FofaClient) AccountInfo(ctx context.Context) (*AccountInfo, error) {
+	var info *AccountInfo
+	err := c.Keys.Do(ctx, func(key keypool.Key) error {
+		var err error
+		info, err = c.fetchAccountInfo(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, redact.Error(err)
+	}
+	return info, nil
+}
+
+// 返回 key 对应账号的信息，使用 accountInfoTTL 内的缓存；查询失败的结果缓存 accountInfoRetryDelay，
+// 期间不再重复查询
+func (c *FofaClient) CachedAccountInfo(ctx context.Context, key keypool.Key) (*AccountInfo, error) {
+	if entry, ok := c.cachedAccount(key); ok {
+		return entry.info, entry.err
+	}
+	var info *AccountInfo
+	err := c.Keys.DoKey(ctx, key, func(key keypool.Key) error {
+		var err error
+		info, err = c.fetchAccountInfo(ctx, key)
+		return err
+	})
+	if err != nil {
+		err = redact.Error(err)
+		c.storeAccount(key, nil, err)
+		return nil, err
+	}
+	return info, nil
+}
+
+// 使用指定的 key 查询账号信息并写入缓存
+func (c *FofaClient) fetchAccountInfo(ctx context.Context, key keypool.Key) (*AccountInfo, error) {
+	body, err := c.getWithKey(ctx, key, fmt.Sprintf("%s/api/v1/info/my", c.BaseURL), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+
+	var info AccountInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	if info.Error {
+		return nil, apiError(info.ErrMsg)
+	}
+
+	c.storeAccount(key, &info, nil)
+	return &info, nil
+}
+
+// 缓存的账号信息或查询错误
+type accountEntry struct {
+	info *AccountInfo
+	err  error
+	at   time.Time
+}
+
+// 返回 key 未过期的缓存
+func (c *FofaClient) cachedAccount(key keypool.Key) (accountEntry, bool) {
+	c.accountMu.Lock()
+	entry, ok := c.accounts[key]
+	c.accountMu.Unlock()
+
+	ttl := accountInfoTTL
+	if entry.err != nil {
+		ttl = accountInfoRetryDelay
+	}
+	return entry, ok && time.Since(entry.at) < ttl
+}
+
+func (c *FofaClient) storeAccount(key keypool.Key, info *AccountInfo, err error) {
+	c.accountMu.Lock()
+	defer c.accountMu.Unlock()
+	if c.accounts == nil {
+		c.accounts = make(map[keypool.Key]accountEntry)
+	}
+	c.accounts[key] = accountEntry{info: info, err: err, at: time.Now()}
+}
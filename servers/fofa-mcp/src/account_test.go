@@ -0,0 +1,127 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/keypool"
+)
+
+func TestCachedAccountInfo(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/info/my" {
+			t.Errorf("请求路径 %s", r.URL.Path)
+		}
+		calls++
+		fmt.Fprint(w, `{"error":false,"email":"user@example.com","isvip":true,"vip_level":12,"fofa_point":100,"remain_api_query":500,"remain_api_data":50000}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		info, err := client.CachedAccountInfo(context.Background(), client.Keys.First())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tier, ok := info.Tier(); !ok || tier != TierProfessional {
+			t.Fatalf("会员等级为 %v，期望专业版", tier)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("请求 %d 次，期望缓存后只请求 1 次", calls)
+	}
+
+	// 直接调用 AccountInfo 总是重新查询
+	if _, err := client.AccountInfo(context.Background()); err != nil || calls != 2 {
+		t.Fatalf("AccountInfo 应重新查询: calls=%d, err=%v", calls, err)
+	}
+}
+
+func TestAccountTierPerKey(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		level := 12
+		if r.URL.Query().Get("key") == "free" {
+			level = 0
+		}
+		fmt.Fprintf(w, `{"error":false,"vip_level":%d}`, level)
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithKeys([]keypool.Key{
+		{Label: "pro", User: "a@example.com", Secret: "pro"},
+		{Label: "free", User: "b@example.com", Secret: "free"},
+	}, ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 搜索可能由任一 key 完成，按池中最低的会员等级检查，查询账号信息使用的 key 记录在 usage 中
+	ctx, usage := keypool.Track(context.Background())
+	check, err := client.CheckFields(ctx, "ip,banner_hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(check.Warnings) != 1 || !strings.Contains(check.Warnings[0], "API Key 池中最低的会员等级为注册用户") {
+		t.Fatalf("提示为 %v", check.Warnings)
+	}
+	if served := usage.Result().ServedBy; fmt.Sprint(served) != "[pro free]" {
+		t.Fatalf("查询账号信息使用的 key 为 %v", served)
+	}
+}
+
+func TestAccountTierLookupFailure(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// cache=only 时不查询账号信息
+	ctx, _ := cache.WithMode(context.Background(), cache.ModeOnly)
+	if check, err := client.CheckFields(ctx, "ip,cname"); err != nil || len(check.Warnings) != 0 || calls != 0 {
+		t.Fatalf("cache=only 时请求 %d 次，结果 %+v, %v", calls, check, err)
+	}
+
+	// 查询失败时不做等级检查，失败结果被缓存，之后的检查不再重复查询
+	for i := 0; i < 3; i++ {
+		if _, err := client.CheckFields(context.Background(), "ip,cname"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("请求 %d 次，期望查询失败后只请求 1 次", calls)
+	}
+}
+
+func TestRestrictedFields(t *testing.T) {
+	fields := "ip,port,cname,banner_hash,icon,product.version"
+	cases := map[AccountTier][]string{
+		TierFree:         {"cname", "banner_hash", "icon", "product.version"},
+		TierPersonal:     {"cname", "icon", "product.version"},
+		TierProfessional: {"icon", "product.version"},
+		TierBusiness:     {"icon"},
+		TierEnterprise:   nil,
+	}
+	for tier, want := range cases {
+		if got := RestrictedFields(ParseFields(fields), tier); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: 得到 %v，期望 %v", tier, got, want)
+		}
+	}
+}
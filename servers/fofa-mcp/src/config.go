@@ -0,0 +1,250 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/config"
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/secrets"
+)
+
+// 服务配置，对应 config.yaml，每一项都可以通过 env 标签中的环境变量覆盖
+type Config struct {
+	Fofa    FofaConfig    `yaml:"fofa"`
+	Server  ServerConfig  `yaml:"server"`
+	Secrets SecretsConfig `yaml:"secrets"`
+}
+
+// FOFA API 配置
+type FofaConfig struct {
+	BaseURL   string `yaml:"base_url" env:"FOFA_BASE_URL"`
+	Timeout   int    `yaml:"timeout" env:"FOFA_TIMEOUT"` // 请求超时时间（秒）
+	Proxy     string `yaml:"proxy" env:"FOFA_PROXY"`
+	UserAgent string `yaml:"user_agent" env:"FOFA_USER_AGENT"`
+	// 会员等级：free、personal、professional、business、enterprise，为空时根据账号信息自动识别
+	AccountTier string `yaml:"account_tier" env:"FOFA_ACCOUNT_TIER"`
+	// 超出会员等级的字段：flag 保留并提示，drop 从请求中移除
+	RestrictedFields string `yaml:"restricted_fields" env:"FOFA_RESTRICTED_FIELDS"`
+	// 网络错误、429 和 502/503/504 响应的最大重试次数，0 表示不重试
+	MaxRetries int `yaml:"max_retries" env:"FOFA_MAX_RETRIES"`
+	// 每个 API Key 每分钟最多请求数，0 表示不限速
+	RateLimit int `yaml:"rate_limit" env:"FOFA_RATE_LIMIT"`
+	// 限速时允许的突发请求数
+	RateBurst int `yaml:"rate_burst" env:"FOFA_RATE_BURST"`
+	// 响应缓存后端：memory、disk 或 none
+	CacheBackend string `yaml:"cache_backend" env:"FOFA_CACHE_BACKEND"`
+	// disk 后端的缓存目录，为空时使用用户缓存目录
+	CacheDir string `yaml:"cache_dir" env:"FOFA_CACHE_DIR"`
+	// 缓存有效期（秒），0 表示不缓存
+	CacheTTL int `yaml:"cache_ttl" env:"FOFA_CACHE_TTL"`
+	// 多个 API Key 时的选择策略：round_robin 轮流使用，quota 优先使用剩余额度最多的 key
+	KeyStrategy string `yaml:"key_strategy" env:"FOFA_KEY_STRATEGY"`
+	// 认证失败或额度不足的 API Key 暂停使用的时长（秒）
+	KeyCooldown int `yaml:"key_cooldown" env:"FOFA_KEY_COOLDOWN"`
+	// 用量预算
+	Budget BudgetConfig `yaml:"budget"`
+}
+
+// 用量预算，各项为 0 表示不限制
+type BudgetConfig struct {
+	ProcessCalls   int `yaml:"process_calls" env:"FOFA_BUDGET_PROCESS_CALLS"`     // 本进程最多请求搜索接口的次数
+	ProcessResults int `yaml:"process_results" env:"FOFA_BUDGET_PROCESS_RESULTS"` // 本进程最多取回的结果数
+	ProcessPoints  int `yaml:"process_points" env:"FOFA_BUDGET_PROCESS_POINTS"`   // 本进程最多消耗的积分
+	DailyCalls     int `yaml:"daily_calls" env:"FOFA_BUDGET_DAILY_CALLS"`         // 每天最多请求搜索接口的次数
+	DailyResults   int `yaml:"daily_results" env:"FOFA_BUDGET_DAILY_RESULTS"`     // 每天最多取回的结果数
+	DailyPoints    int `yaml:"daily_points" env:"FOFA_BUDGET_DAILY_POINTS"`       // 每天最多消耗的积分
+	// 每日用量的保存文件，为空时不保存，重启后重新计数
+	File string `yaml:"file" env:"FOFA_BUDGET_FILE"`
+}
+
+// 本进程的用量上限
+func (b BudgetConfig) ProcessLimits() budget.Limits {
+	return budget.Limits{Calls: b.ProcessCalls, Results: b.ProcessResults, Points: b.ProcessPoints}
+}
+
+// 每天的用量上限
+func (b BudgetConfig) DailyLimits() budget.Limits {
+	return budget.Limits{Calls: b.DailyCalls, Results: b.DailyResults, Points: b.DailyPoints}
+}
+
+// MCP 服务标识
+type ServerConfig struct {
+	Name    string `yaml:"name" env:"FOFA_MCP_NAME"`
+	Version string `yaml:"version" env:"FOFA_MCP_VERSION"`
+}
+
+// 凭证来源。API Key 依次从环境变量、NAME_FILE 指定的文件、加密凭证文件和辅助命令中查找
+type SecretsConfig struct {
+	// 加密的凭证文件，由 mcp-secrets 工具生成，为空时不使用
+	File string `yaml:"file" env:"FOFA_MCP_SECRETS_FILE"`
+	// 加密凭证文件的口令文件
+	PassphraseFile string `yaml:"passphrase_file" env:"FOFA_MCP_SECRETS_PASSPHRASE_FILE"`
+	// 辅助命令，以凭证名称作为最后一个参数调用，标准输出为凭证，为空时不使用
+	Exec string `yaml:"exec" env:"FOFA_MCP_SECRETS_EXEC"`
+	// 辅助命令表示凭证不存在的退出码，为 0 时任何非 0 退出码都视为错误
+	ExecNotFound int `yaml:"exec_not_found_code" env:"FOFA_MCP_SECRETS_EXEC_NOT_FOUND_CODE"`
+}
+
+// 凭证来源的选项
+func (s SecretsConfig) Options() secrets.Options {
+	return secrets.Options{File: s.File, PassphraseFile: s.PassphraseFile, Exec: s.Exec, ExecNotFound: s.ExecNotFound}
+}
+
+// 默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Fofa: FofaConfig{
+			BaseURL:          "https://fofa.info",
+			Timeout:          30,
+			UserAgent:        "fofa-mcp/1.0",
+			RestrictedFields: string(FieldPolicyFlag),
+			MaxRetries:       3,
+			RateBurst:        1,
+			CacheBackend:     cache.BackendMemory,
+			CacheTTL:         3600,
+			KeyStrategy:      string(keypool.RoundRobin),
+			KeyCooldown:      3600,
+		},
+		Server: ServerConfig{
+			Name:    "fofa-mcp",
+			Version: "1.0.0",
+		},
+	}
+}
+
+// 加载配置：默认值 < 配置文件 < 环境变量，path 为空时不读取配置文件
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if err := config.Load(path, cfg); err != nil {
+		return nil, err
+	}
+	if err := config.ApplyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// 从凭证来源读取 API Key：FOFA_KEYS 设置多个账号，每项为 [名称=]邮箱:API Key，以逗号或换行分隔；
+// 未设置时使用 FOFA_EMAIL 和 FOFA_KEY。都未设置时返回空列表。
+// 辅助命令查找不存在的凭证时可能以非 0 退出码结束，读取 FOFA_KEYS 出错时仍尝试单个账号，
+// 单个账号也未设置时才返回该错误
+func LoadKeys(ctx context.Context, store secrets.Chain) ([]keypool.Key, error) {
+	list, listErr := store.Get(ctx, "FOFA_KEYS")
+	if list != "" {
+		keys, err := keypool.Parse(list, true)
+		if err != nil {
+			return nil, fmt.Errorf("FOFA_KEYS 无效: %w", err)
+		}
+		return keys, nil
+	}
+	email, err := store.Get(ctx, "FOFA_EMAIL")
+	if err != nil {
+		return nil, err
+	}
+	key, err := store.Get(ctx, "FOFA_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if email == "" || key == "" {
+		return nil, listErr
+	}
+	return []keypool.Key{{User: email, Secret: key}}, nil
+}
+
+// 校验配置
+func (c *Config) Validate() error {
+	if err := config.ValidateHTTPURL("fofa.base_url", c.Fofa.BaseURL); err != nil {
+		return err
+	}
+	if c.Fofa.Timeout <= 0 {
+		return fmt.Errorf("配置项 fofa.timeout 必须大于 0，当前为 %d", c.Fofa.Timeout)
+	}
+	if err := config.ValidateProxyURL("fofa.proxy", c.Fofa.Proxy); err != nil {
+		return err
+	}
+	if c.Fofa.UserAgent == "" {
+		return fmt.Errorf("配置项 fofa.user_agent 不能为空")
+	}
+	if c.Fofa.AccountTier != "" {
+		if _, err := ParseAccountTier(c.Fofa.AccountTier); err != nil {
+			return fmt.Errorf("配置项 fofa.account_tier 无效: %w", err)
+		}
+	}
+	switch FieldPolicy(c.Fofa.RestrictedFields) {
+	case FieldPolicyFlag, FieldPolicyDrop:
+	default:
+		return fmt.Errorf("配置项 fofa.restricted_fields 必须为 flag 或 drop，当前为 %q", c.Fofa.RestrictedFields)
+	}
+	if c.Fofa.MaxRetries < 0 {
+		return fmt.Errorf("配置项 fofa.max_retries 不能小于 0，当前为 %d", c.Fofa.MaxRetries)
+	}
+	if c.Fofa.RateLimit < 0 {
+		return fmt.Errorf("配置项 fofa.rate_limit 不能小于 0，当前为 %d", c.Fofa.RateLimit)
+	}
+	if c.Fofa.RateBurst < 1 {
+		return fmt.Errorf("配置项 fofa.rate_burst 必须大于 0，当前为 %d", c.Fofa.RateBurst)
+	}
+	switch c.Fofa.CacheBackend {
+	case cache.BackendMemory, cache.BackendDisk, cache.BackendNone:
+	default:
+		return fmt.Errorf("配置项 fofa.cache_backend 必须为 memory、disk 或 none，当前为 %q", c.Fofa.CacheBackend)
+	}
+	if c.Fofa.CacheTTL < 0 {
+		return fmt.Errorf("配置项 fofa.cache_ttl 不能小于 0，当前为 %d", c.Fofa.CacheTTL)
+	}
+	if _, err := keypool.ParseStrategy(c.Fofa.KeyStrategy); err != nil {
+		return fmt.Errorf("配置项 fofa.key_strategy 无效: %w", err)
+	}
+	if c.Fofa.KeyCooldown <= 0 {
+		return fmt.Errorf("配置项 fofa.key_cooldown 必须大于 0，当前为 %d", c.Fofa.KeyCooldown)
+	}
+	if err := c.Fofa.Budget.ProcessLimits().Validate("fofa.budget.process"); err != nil {
+		return err
+	}
+	if err := c.Fofa.Budget.DailyLimits().Validate("fofa.budget.daily"); err != nil {
+		return err
+	}
+	if c.Server.Name == "" {
+		return fmt.Errorf("配置项 server.name 不能为空")
+	}
+	if c.Server.Version == "" {
+		return fmt.Errorf("配置项 server.version 不能为空")
+	}
+	return nil
+}
+
+// 转换为客户端配置，调用前应已通过 Validate 校验
+func (c *Config) ClientConfig() ClientConfig {
+	cfg := ClientConfig{
+		BaseURL:     c.Fofa.BaseURL,
+		Timeout:     time.Duration(c.Fofa.Timeout) * time.Second,
+		Proxy:       c.Fofa.Proxy,
+		UserAgent:   c.Fofa.UserAgent,
+		FieldPolicy: FieldPolicy(c.Fofa.RestrictedFields),
+		MaxRetries:  c.Fofa.MaxRetries,
+		RateLimit:   c.Fofa.RateLimit,
+		RateBurst:   c.Fofa.RateBurst,
+
+		CacheBackend: c.Fofa.CacheBackend,
+		CacheDir:     c.Fofa.CacheDir,
+		CacheTTL:     time.Duration(c.Fofa.CacheTTL) * time.Second,
+
+		ProcessBudget: c.Fofa.Budget.ProcessLimits(),
+		DailyBudget:   c.Fofa.Budget.DailyLimits(),
+		BudgetFile:    c.Fofa.Budget.File,
+
+		KeyStrategy: keypool.Strategy(c.Fofa.KeyStrategy),
+		KeyCooldown: time.Duration(c.Fofa.KeyCooldown) * time.Second,
+	}
+	if tier, err := ParseAccountTier(c.Fofa.AccountTier); err == nil {
+		cfg.AccountTier = &tier
+	}
+	return cfg
+}
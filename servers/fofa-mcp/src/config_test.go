@@ -0,0 +1,36 @@
+package src
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/secrets"
+)
+
+func TestLoadKeysHelperMiss(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("辅助命令测试使用 shell 脚本")
+	}
+	// 辅助命令只保存了单个账号，查找 FOFA_KEYS 时以退出码 1 结束
+	script := filepath.Join(t.TempDir(), "helper.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\ncase \"$1\" in\nFOFA_EMAIL) echo user@example.com ;;\nFOFA_KEY) echo secret ;;\n*) exit 1 ;;\nesac\n"), 0o700)
+	helper, err := secrets.NewExec(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := LoadKeys(context.Background(), secrets.Chain{helper})
+	if err != nil || len(keys) != 1 || keys[0] != (keypool.Key{User: "user@example.com", Secret: "secret"}) {
+		t.Fatalf("keys=%+v, err=%v", keys, err)
+	}
+
+	// 单个账号也未设置时返回读取 FOFA_KEYS 的错误
+	os.WriteFile(script, []byte("#!/bin/sh\ncase \"$1\" in\nFOFA_KEYS) echo broken >&2; exit 2 ;;\nesac\n"), 0o700)
+	if _, err := LoadKeys(context.Background(), secrets.Chain{helper}); err == nil {
+		t.Fatal("期望返回错误")
+	}
+}
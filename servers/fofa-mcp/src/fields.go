@@ -0,0 +1,305 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/dork"
+)
+
+// FOFA 会员等级，决定可用的返回字段
+type AccountTier int
+
+const (
+	TierFree         AccountTier = iota // 注册用户，可用字段 1-33
+	TierPersonal                        // 个人版，可用字段 34-36
+	TierProfessional                    // 专业版，可用字段 37-40
+	TierBusiness                        // 商业版，可用字段 41-47
+	TierEnterprise                      // 企业会员，可用字段 48-50
+)
+
+// 配置文件中使用的会员等级名称
+var tierNames = map[string]AccountTier{
+	"free":         TierFree,
+	"personal":     TierPersonal,
+	"professional": TierProfessional,
+	"business":     TierBusiness,
+	"enterprise":   TierEnterprise,
+}
+
+func (t AccountTier) String() string {
+	switch t {
+	case TierFree:
+		return "注册用户"
+	case TierPersonal:
+		return "个人版"
+	case TierProfessional:
+		return "专业版"
+	case TierBusiness:
+		return "商业版"
+	case TierEnterprise:
+		return "企业会员"
+	}
+	return fmt.Sprintf("未知等级(%d)", int(t))
+}
+
+// 解析会员等级名称：free、personal、professional、business、enterprise
+func ParseAccountTier(name string) (AccountTier, error) {
+	if tier, ok := tierNames[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return tier, nil
+	}
+	return 0, fmt.Errorf("未知的会员等级 %q，可选值：free、personal、professional、business、enterprise", name)
+}
+
+// 超出会员等级的字段的处理方式
+type FieldPolicy string
+
+const (
+	FieldPolicyFlag FieldPolicy = "flag" // 保留字段，在结果中提示将返回空值
+	FieldPolicyDrop FieldPolicy = "drop" // 从请求中移除字段，并在结果中提示
+)
+
+// 字段目录，按 FOFA 文档中的编号顺序排列
+var fieldCatalogue = []struct {
+	tier   AccountTier
+	fields []string
+}{
+	{TierFree, []string{
+		"ip", "port", "protocol", "country", "country_name", "region", "city", "longitude", "latitude",
+		"asn", "org", "host", "domain", "os", "server", "icp", "title", "jarm", "header", "banner", "cert",
+		"base_protocol", "link", "cert.issuer.org", "cert.issuer.cn", "cert.subject.org", "cert.subject.cn",
+		"tls.ja3s", "tls.version", "cert.sn", "cert.not_before", "cert.not_after", "cert.domain",
+	}},
+	{TierPersonal, []string{"header_hash", "banner_hash", "banner_fid"}},
+	{TierProfessional, []string{"cname", "lastupdatetime", "product", "product_category"}},
+	{TierBusiness, []string{"product.version", "icon_hash", "cert.is_valid", "cname_domain", "body", "cert.is_match", "cert.is_equal"}},
+	{TierEnterprise, []string{"icon", "fid", "structinfo"}},
+}
+
+// 字段所需的最低会员等级
+var fieldTiers = func() map[string]AccountTier {
+	tiers := make(map[string]AccountTier)
+	for _, group := range fieldCatalogue {
+		for _, field := range group.fields {
+			tiers[field] = group.tier
+		}
+	}
+	return tiers
+}()
+
+// 判断是否为 FOFA 支持的返回字段
+func IsKnownField(field string) bool {
+	_, ok := fieldTiers[field]
+	return ok
+}
+
+// 返回字段所需的最低会员等级
+func FieldTier(field string) AccountTier {
+	return fieldTiers[field]
+}
+
+// 未指定 fields 时请求的字段
+const DefaultFields = "host,ip,port,protocol"
+
+// 单页最多返回的结果数量
+const MaxPageSize = 10000
+
+// 会降低单页上限的字段：包含这些字段时，每页最多返回对应数量的结果
+var fieldSizeLimits = map[string]int{
+	"cert":   2000,
+	"banner": 2000,
+}
+
+// 解析后的返回字段列表，字段名已规范化为小写并去重，保持请求顺序
+type FieldList []string
+
+// 解析逗号分隔的字段列表：去除空白、转换为小写、去掉空项和重复字段
+func ParseFields(fields string) FieldList {
+	var list FieldList
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(fields, ",") {
+		field = strings.ToLower(strings.Join(strings.Fields(field), ""))
+		if field == "" || seen[field] {
+			continue
+		}
+		seen[field] = true
+		list = append(list, field)
+	}
+	return list
+}
+
+// 返回逗号分隔的字段列表，用于 API 请求
+func (f FieldList) String() string {
+	return strings.Join(f, ",")
+}
+
+// 返回单页上限以及限制该上限的字段，没有字段限制时字段为空
+func (f FieldList) PageSizeLimit() (int, string) {
+	limit, limitedBy := MaxPageSize, ""
+	for _, field := range f {
+		if n, ok := fieldSizeLimits[field]; ok && n < limit {
+			limit, limitedBy = n, field
+		}
+	}
+	return limit, limitedBy
+}
+
+// 将每页数量限制在单页上限以内，发生调整时返回说明
+func (f FieldList) ClampSize(size int) (int, string) {
+	limit, limitedBy := f.PageSizeLimit()
+	if size <= limit {
+		return size, ""
+	}
+	if limitedBy == "" {
+		return limit, fmt.Sprintf("size %d 超过单页上限，已调整为 %d", size, limit)
+	}
+	return limit, fmt.Sprintf("size %d 超过单页上限，已调整为 %d（fields 包含 %s 字段时每页最多 %d 条）", size, limit, limitedBy, limit)
+}
+
+// 返回字段列表中超出账号等级的字段，按请求顺序排列
+func RestrictedFields(fields FieldList, tier AccountTier) []string {
+	var restricted []string
+	for _, field := range fields {
+		if FieldTier(field) > tier {
+			restricted = append(restricted, field)
+		}
+	}
+	return restricted
+}
+
+// 为未知字段推荐最接近的已知字段，最多3个
+func suggestFields(field string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	lower := strings.ToLower(field)
+	for _, group := range fieldCatalogue {
+		for _, name := range group.fields {
+			distance := dork.EditDistance(lower, name)
+			if distance <= 2 || (len(lower) >= 3 && strings.Contains(name, lower)) {
+				candidates = append(candidates, candidate{name, distance})
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < 3; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// 校验返回字段，拒绝未知字段并给出相近的字段建议
+func ValidateFields(fields FieldList) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("fields参数中没有有效字段")
+	}
+
+	var unknown []string
+	for _, field := range fields {
+		if IsKnownField(field) {
+			continue
+		}
+		if suggestions := suggestFields(field); len(suggestions) > 0 {
+			unknown = append(unknown, fmt.Sprintf("%s（是否想使用 %s？）", field, strings.Join(suggestions, "、")))
+		} else {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("fields参数包含未知字段: %s", strings.Join(unknown, "；"))
+	}
+	return nil
+}
+
+// 字段校验结果
+type FieldCheck struct {
+	Fields   FieldList // 实际请求的字段列表
+	Warnings []string  // 超出会员等级等提示
+}
+
+// 解析并校验返回字段：未知字段返回错误；超出会员等级的字段按 FieldPolicy 移除或提示。
+// 会员等级优先使用配置的 AccountTier，未配置时使用各 API Key 缓存的账号信息，获取失败时不做等级检查
+func (c *FofaClient) CheckFields(ctx context.Context, raw string) (*FieldCheck, error) {
+	fields := ParseFields(raw)
+	if err := ValidateFields(fields); err != nil {
+		return nil, err
+	}
+
+	check := &FieldCheck{Fields: fields}
+	tier, ok := c.accountTier(ctx)
+	if !ok {
+		return check, nil
+	}
+
+	restricted := RestrictedFields(fields, tier)
+	if len(restricted) == 0 {
+		return check, nil
+	}
+	details := make([]string, len(restricted))
+	for i, field := range restricted {
+		details[i] = fmt.Sprintf("%s（%s及以上）", field, FieldTier(field))
+	}
+	account := fmt.Sprintf("当前账号为%s", tier)
+	if c.AccountTier == nil && c.Keys.Len() > 1 {
+		account = fmt.Sprintf("API Key 池中最低的会员等级为%s", tier)
+	}
+
+	if c.FieldPolicy != FieldPolicyDrop {
+		check.Warnings = append(check.Warnings, fmt.Sprintf("%s，以下字段超出会员权限，将返回空值：%s", account, strings.Join(details, "、")))
+		return check, nil
+	}
+
+	var allowed FieldList
+	for _, field := range fields {
+		if FieldTier(field) <= tier {
+			allowed = append(allowed, field)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, fmt.Errorf("fields参数中的字段均超出当前账号（%s）的会员权限: %s", tier, strings.Join(details, "、"))
+	}
+	check.Fields = allowed
+	check.Warnings = append(check.Warnings, fmt.Sprintf("%s，已移除超出会员权限的字段：%s", account, strings.Join(details, "、")))
+	return check, nil
+}
+
+// 返回用于字段权限检查的会员等级。未配置 AccountTier 时查询池中每个可用 key 的会员等级，
+// 搜索可能由其中任何一个 key 完成，因此取最低的等级；cache=only 时只使用已缓存的账号信息
+func (c *FofaClient) accountTier(ctx context.Context) (AccountTier, bool) {
+	if c.AccountTier != nil {
+		return *c.AccountTier, true
+	}
+
+	onlyCached := cache.ModeFrom(ctx) == cache.ModeOnly
+	var lowest AccountTier
+	found := false
+	for _, key := range c.Keys.Available() {
+		var info *AccountInfo
+		var err error
+		if onlyCached {
+			entry, ok := c.cachedAccount(key)
+			if !ok {
+				continue
+			}
+			info, err = entry.info, entry.err
+		} else {
+			info, err = c.CachedAccountInfo(ctx, key)
+		}
+		if err != nil {
+			continue
+		}
+		if tier, ok := info.Tier(); ok && (!found || tier < lowest) {
+			lowest, found = tier, true
+		}
+	}
+	return lowest, found
+}
@@ -0,0 +1,89 @@
+package src
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestValidateFields(t *testing.T) {
+	if err := ValidateFields(ParseFields("ip,port,cert.issuer.org,structinfo")); err != nil {
+		t.Fatalf("已知字段不应报错: %v", err)
+	}
+
+	err := ValidateFields(ParseFields("ip,titel,issuer,zzzz"))
+	if err == nil {
+		t.Fatal("未知字段应报错")
+	}
+	for _, want := range []string{"titel（是否想使用 title", "cert.issuer.org", "zzzz"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息缺少 %q: %v", want, err)
+		}
+	}
+}
+
+func TestCheckFields(t *testing.T) {
+	tier := TierPersonal
+	client := NewFofaClient("user@example.com", "key")
+	client.AccountTier = &tier
+
+	check, err := client.CheckFields(context.Background(), "ip,cname,banner_hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Fields.String() != "ip,cname,banner_hash" || len(check.Warnings) != 1 || !strings.Contains(check.Warnings[0], "cname（专业版及以上）") {
+		t.Fatalf("flag 模式应保留字段并提示: %+v", check)
+	}
+
+	client.FieldPolicy = FieldPolicyDrop
+	check, err = client.CheckFields(context.Background(), "ip,cname,banner_hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.Fields.String() != "ip,banner_hash" || len(check.Warnings) != 1 || !strings.Contains(check.Warnings[0], "已移除") {
+		t.Fatalf("drop 模式应移除字段并提示: %+v", check)
+	}
+
+	if _, err := client.CheckFields(context.Background(), "icon,fid"); err == nil {
+		t.Fatal("所有字段都被移除时应报错")
+	}
+}
+
+func TestParseAccountTier(t *testing.T) {
+	if tier, err := ParseAccountTier(" Business "); err != nil || tier != TierBusiness {
+		t.Fatalf("解析 business 得到 %v, %v", tier, err)
+	}
+	if _, err := ParseAccountTier("gold"); err == nil {
+		t.Fatal("未知等级应报错")
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	fields := ParseFields(" IP, port ,, Cert . Issuer . Org,ip,PORT ")
+	if got := fields.String(); got != "ip,port,cert.issuer.org" {
+		t.Fatalf("解析结果 %q", got)
+	}
+}
+
+func TestClampSize(t *testing.T) {
+	cases := []struct {
+		fields string
+		size   int
+		want   int
+		notice string
+	}{
+		{"ip,port", 5000, 5000, ""},
+		{"ip,port", 20000, 10000, "已调整为 10000"},
+		{"ip,cert", 5000, 2000, "包含 cert 字段"},
+		{"ip,banner", 5000, 2000, "包含 banner 字段"},
+		// 只有 cert 和 banner 本身会降低上限，名称相近的字段不受影响
+		{"ip,cert.issuer.org,banner_hash", 5000, 5000, ""},
+		{"ip,cert", 1500, 1500, ""},
+	}
+	for _, tc := range cases {
+		size, notice := ParseFields(tc.fields).ClampSize(tc.size)
+		if size != tc.want || (tc.notice == "") != (notice == "") || !strings.Contains(notice, tc.notice) {
+			t.Errorf("%s size=%d: 得到 %d %q，期望 %d %q", tc.fields, tc.size, size, notice, tc.want, tc.notice)
+		}
+	}
+}
@@ -1,23 +1,71 @@
 package src
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/dork"
+	"securitymcp-hub/pkg/httpx"
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/redact"
 )
 
 // FOFA API 客户端
 type FofaClient struct {
-	Email   string
-	Key     string
-	BaseURL string
-	Client  *http.Client
+	Keys      *keypool.Pool // API Key 池，每个 key 包含邮箱和 API Key
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+
+	// 字段权限检查：AccountTier 为 nil 时根据账号信息自动识别会员等级
+	AccountTier *AccountTier
+	FieldPolicy FieldPolicy
+
+	// 搜索、统计和主机信息的响应缓存，nil 表示不缓存
+	Cache *cache.Cache
+
+	// 搜索接口的用量预算，nil 表示不限制
+	Budget *budget.Guard
+
+	// 按 API Key 缓存的账号信息，用于判断会员等级
+	accountMu sync.Mutex
+	accounts  map[keypool.Key]accountEntry
+}
+
+// 客户端配置
+type ClientConfig struct {
+	BaseURL     string
+	Timeout     time.Duration
+	Proxy       string
+	UserAgent   string
+	AccountTier *AccountTier
+	FieldPolicy FieldPolicy
+	MaxRetries  int // 网络错误、429 和 502/503/504 响应的最大重试次数
+	RateLimit   int // 每个 API Key 每分钟最多请求数，0 表示不限速
+	RateBurst   int // 限速时允许的突发请求数
+
+	CacheBackend string        // 响应缓存后端：memory、disk 或 none
+	CacheDir     string        // disk 后端的缓存目录，为空时使用用户缓存目录
+	CacheTTL     time.Duration // 缓存有效期，0 表示不缓存
+
+	ProcessBudget budget.Limits // 本进程的搜索用量上限
+	DailyBudget   budget.Limits // 每天的搜索用量上限
+	BudgetFile    string        // 每日用量的保存文件，为空时不保存
+
+	KeyStrategy keypool.Strategy // 多个 API Key 时的选择策略
+	KeyCooldown time.Duration    // 认证失败或额度不足的 key 暂停使用的时长
 }
 
 // 查询参数结构
@@ -32,13 +80,29 @@ type QueryParams struct {
 
 // 搜索结果响应
 type SearchResponse struct {
-	Error   bool       `json:"error"`
-	ErrMsg  string     `json:"errmsg,omitempty"`
-	Size    int        `json:"size"`
-	Page    int        `json:"page"`
-	Mode    string     `json:"mode"`
-	Query   string     `json:"query"`
-	Results [][]string `json:"results"`
+	Error          bool     `json:"error"`
+	ErrMsg         string   `json:"errmsg,omitempty"`
+	Size           int      `json:"size"` // 查询结果总数
+	Page           int      `json:"page"`
+	Mode           string   `json:"mode"`
+	Query          string   `json:"query"`
+	Results        []Record `json:"results"`         // 按请求字段命名的结果
+	ConsumedFpoint int      `json:"consumed_fpoint"` // 本次请求消耗的F点，免费额度内为0
+}
+
+// 连续翻页查询参数
+type SearchNextParams struct {
+	Query  string `json:"query"`  // 查询语句
+	Size   int    `json:"size"`   // 每页数量，最大10000
+	Fields string `json:"fields"` // 返回字段，逗号分隔
+	Full   bool   `json:"full"`   // 是否全量数据
+	Next   string `json:"next"`   // 上一页返回的游标，首次查询为空
+}
+
+// 连续翻页响应，Next 为空表示没有更多结果
+type SearchNextResponse struct {
+	SearchResponse
+	Next string `json:"next"`
 }
 
 // 统计响应
@@ -52,53 +116,231 @@ type StatsResponse struct {
 // 主机信息响应 - 使用 map 动态处理所有返回字段，不写死
 type HostInfoResponse map[string]interface{}
 
-// 创建新的FOFA客户端
+// 创建新的FOFA客户端，使用默认配置
 func NewFofaClient(email, key string) *FofaClient {
 	return &FofaClient{
-		Email:   email,
-		Key:     key,
-		BaseURL: "https://fofa.info",
+		Keys:      keypool.Single(keypool.Key{User: email, Secret: key}),
+		BaseURL:   "https://fofa.info",
+		UserAgent: "fofa-mcp/1.0",
 		Client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		FieldPolicy: FieldPolicyFlag,
 	}
 }
 
+// 按配置创建FOFA客户端，用于私有部署或测试环境
+func NewFofaClientWithConfig(email, key string, cfg ClientConfig) (*FofaClient, error) {
+	return NewFofaClientWithKeys([]keypool.Key{{User: email, Secret: key}}, cfg)
+}
+
+// 按配置创建使用多个 API Key 的FOFA客户端，key 的 User 为邮箱
+func NewFofaClientWithKeys(keys []keypool.Key, cfg ClientConfig) (*FofaClient, error) {
+	retry := httpx.DefaultRetryPolicy
+	retry.MaxRetries = cfg.MaxRetries
+	httpClient, err := httpx.NewClient(httpx.Options{
+		Timeout:   cfg.Timeout,
+		Proxy:     cfg.Proxy,
+		Retry:     retry,
+		RateLimit: float64(cfg.RateLimit) / 60,
+		RateBurst: cfg.RateBurst,
+		// FOFA 的 API Key 在查询参数中
+		RateKey: func(r *http.Request) string { return r.URL.Query().Get("key") },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responseCache, err := cache.Open(cfg.CacheBackend, cfg.CacheDir, "fofa", cfg.CacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	guard, err := budget.Open(cfg.ProcessBudget, cfg.DailyBudget, cfg.BudgetFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &FofaClient{
+		BaseURL:     strings.TrimRight(cfg.BaseURL, "/"),
+		UserAgent:   cfg.UserAgent,
+		Client:      httpClient,
+		AccountTier: cfg.AccountTier,
+		FieldPolicy: cfg.FieldPolicy,
+		Cache:       responseCache,
+		Budget:      guard,
+	}
+	client.Keys, err = keypool.New(keys, keypool.Options{
+		Strategy: cfg.KeyStrategy,
+		Cooldown: cfg.KeyCooldown,
+		Quota:    client.keyQuota,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
 // 对查询语句进行Base64编码
 func (c *FofaClient) encodeQuery(query string) string {
 	return base64.StdEncoding.EncodeToString([]byte(query))
 }
 
-// 执行搜索查询
-func (c *FofaClient) Search(params QueryParams) (*SearchResponse, error) {
-	// 参数验证和默认值
-	if params.Page < 1 {
-		params.Page = 1
+// FOFA 在 200 响应中报告的错误，根据错误信息判断类型
+func apiError(errMsg string) error {
+	return httpx.NewAPIError(httpx.ClassifyMessage(errMsg), fmt.Sprintf("FOFA API错误: %s", errMsg))
+}
+
+// 使用 key 池中的 API Key 发送 GET 请求并返回响应体，认证失败或额度不足时换用下一个 key。
+// 返回的错误信息中不包含凭证
+func (c *FofaClient) get(ctx context.Context, apiURL string, queryValues url.Values) ([]byte, error) {
+	var body []byte
+	err := c.Keys.Do(ctx, func(key keypool.Key) error {
+		var err error
+		body, err = c.getWithKey(ctx, key, apiURL, queryValues)
+		return err
+	})
+	return body, redact.Error(err)
+}
+
+// 使用指定的 API Key 发送 GET 请求并返回响应体，非 200 状态码和 API 报告的错误视为错误
+func (c *FofaClient) getWithKey(ctx context.Context, key keypool.Key, apiURL string, queryValues url.Values) ([]byte, error) {
+	values := url.Values{}
+	for k, v := range queryValues {
+		values[k] = v
 	}
-	if params.Size < 1 {
-		params.Size = 100
+	values.Set("email", key.User)
+	values.Set("key", key.Secret)
+	fullURL := fmt.Sprintf("%s?%s", apiURL, values.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		// net/http 的错误信息包含完整 URL，去掉带有 email 和 key 的查询参数
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = apiURL
+		}
+		return nil, fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("读取响应失败: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, httpx.NewStatusError(resp, fmt.Sprintf("API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body)))
 	}
 
-	// 检查是否包含cert或banner字段，如果包含则限制size最大为2000
-	hasCertOrBanner := false
-	if params.Fields != "" {
-		fieldsLower := strings.ToLower(params.Fields)
-		hasCertOrBanner = strings.Contains(fieldsLower, "cert") || strings.Contains(fieldsLower, "banner")
+	var status struct {
+		Error  bool   `json:"error"`
+		ErrMsg string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(body, &status); err == nil && status.Error {
+		return nil, apiError(status.ErrMsg)
 	}
 
-	if hasCertOrBanner {
-		if params.Size > 2000 {
-			params.Size = 2000
+	return body, nil
+}
+
+// 查询 API Key 当月剩余可返回的数据条数，用于按剩余额度选择 key
+func (c *FofaClient) keyQuota(ctx context.Context, key keypool.Key) (int, error) {
+	info, err := c.fetchAccountInfo(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	return info.RemainAPIData, nil
+}
+
+// 超出免费额度后每条结果预计消耗的F点，用于请求前预留积分预算
+const pointsPerResult = 1
+
+// 预计一次请求消耗的F点：所有可用 key 当月剩余的免费数据条数都不少于 size 时为 0，
+// 否则（包括查询账号信息失败时）按每条结果 pointsPerResult F点估算。
+// 未限制积分预算时不查询账号信息
+func (c *FofaClient) estimatePoints(ctx context.Context, size int) int {
+	if !c.Budget.LimitsPoints() {
+		return 0
+	}
+	keys := c.Keys.Available()
+	if len(keys) == 0 {
+		return size * pointsPerResult
+	}
+	for _, key := range keys {
+		info, err := c.CachedAccountInfo(ctx, key)
+		if err != nil || info.RemainAPIData < size {
+			return size * pointsPerResult
 		}
-	} else {
-		if params.Size > 10000 {
-			params.Size = 10000
+	}
+	return 0
+}
+
+// 在用量预算内请求搜索接口：按每页数量预留结果数和预计消耗的F点，
+// 按实际返回的结果数和 consumed_fpoint 结算
+func (c *FofaClient) budgetedGet(ctx context.Context, apiURL string, queryValues url.Values, size int) ([]byte, error) {
+	reservation, err := c.Budget.Reserve(budget.Usage{Calls: 1, Results: size, Points: c.estimatePoints(ctx, size)})
+	if err != nil {
+		return nil, err
+	}
+	body, err := c.get(ctx, apiURL, queryValues)
+	used := budget.Usage{Calls: 1}
+	if err == nil {
+		var resp struct {
+			Results        []json.RawMessage `json:"results"`
+			ConsumedFpoint int               `json:"consumed_fpoint"`
+		}
+		if json.Unmarshal(body, &resp) == nil {
+			used.Results = len(resp.Results)
+			used.Points = resp.ConsumedFpoint
 		}
 	}
+	reservation.Settle(used)
+	return body, err
+}
+
+// 规范化查询语句用作缓存键，使只有空白或引号不同的查询共用缓存；无法解析时只去除首尾空白
+func normalizeQuery(query string) string {
+	if expr, err := dork.FOFA.Parse(query); err == nil {
+		return expr.String()
+	}
+	return strings.TrimSpace(query)
+}
+
+// 缓存键中的会员等级：返回的字段内容和 full 的数据范围取决于账号等级，
+// 不同等级的 key 不共用缓存；等级未知或不使用缓存时为 nil
+func (c *FofaClient) cacheTier(ctx context.Context) interface{} {
+	if c.Cache == nil || cache.ModeFrom(ctx) == cache.ModeBypass {
+		return nil
+	}
+	if tier, ok := c.accountTier(ctx); ok {
+		return tier
+	}
+	return nil
+}
+
+// 执行搜索查询
+func (c *FofaClient) Search(ctx context.Context, params QueryParams) (*SearchResponse, error) {
+	// 参数验证和默认值
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.Size < 1 {
+		params.Size = 100
+	}
 
-	if params.Fields == "" {
-		params.Fields = "host,ip,port,protocol"
+	fields := ParseFields(params.Fields)
+	if len(fields) == 0 {
+		fields = ParseFields(DefaultFields)
 	}
+	params.Size, _ = fields.ClampSize(params.Size)
 
 	// 构建URL
 	apiURL := fmt.Sprintf("%s/api/v1/search/all", c.BaseURL)
@@ -108,12 +350,10 @@ func (c *FofaClient) Search(params QueryParams) (*SearchResponse, error) {
 
 	// 构建查询参数
 	queryValues := url.Values{}
-	queryValues.Set("email", c.Email)
-	queryValues.Set("key", c.Key)
 	queryValues.Set("qbase64", queryBase64)
 	queryValues.Set("page", strconv.Itoa(params.Page))
 	queryValues.Set("size", strconv.Itoa(params.Size))
-	queryValues.Set("fields", params.Fields)
+	queryValues.Set("fields", fields.String())
 	if params.Full {
 		queryValues.Set("full", "true")
 	}
@@ -121,80 +361,99 @@ func (c *FofaClient) Search(params QueryParams) (*SearchResponse, error) {
 		queryValues.Set("is_domain", "true")
 	}
 
-	fullURL := fmt.Sprintf("%s?%s", apiURL, queryValues.Encode())
-
-	// 发送请求
-	req, err := http.NewRequest("GET", fullURL, nil)
+	// get 会把 API 报告的错误转换为 error，错误的响应不会被缓存
+	key := cache.Key("fofa", "search", c.cacheTier(ctx), normalizeQuery(params.Query), params.Page, params.Size, fields.String(), params.Full, params.IsDomain)
+	body, err := c.Cache.Fetch(ctx, key, func() ([]byte, error) {
+		return c.budgetedGet(ctx, apiURL, queryValues, params.Size)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("User-Agent", "fofa-mcp/1.0")
+	// 解析响应
+	var raw struct {
+		SearchResponse
+		Results rawRows `json:"results"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
 
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+	if raw.Error {
+		return nil, apiError(raw.ErrMsg)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
+	searchResp := raw.SearchResponse
+	searchResp.Results = zipRecords(fields, raw.Results)
+	return &searchResp, nil
+}
+
+// 通过 search/next 接口连续翻页，使用上一页返回的游标获取下一页，
+// 结果集在游标间保持一致，不会因深度翻页限制或数据更新出现重复或遗漏
+func (c *FofaClient) SearchNext(ctx context.Context, params SearchNextParams) (*SearchNextResponse, error) {
+	if params.Size < 1 {
+		params.Size = 100
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+	fields := ParseFields(params.Fields)
+	if len(fields) == 0 {
+		fields = ParseFields(DefaultFields)
 	}
+	params.Size, _ = fields.ClampSize(params.Size)
 
-	// 解析响应
-	var searchResp SearchResponse
-	if err := json.Unmarshal(body, &searchResp); err != nil {
+	apiURL := fmt.Sprintf("%s/api/v1/search/next", c.BaseURL)
+
+	queryValues := url.Values{}
+	queryValues.Set("qbase64", c.encodeQuery(params.Query))
+	queryValues.Set("size", strconv.Itoa(params.Size))
+	queryValues.Set("fields", fields.String())
+	if params.Full {
+		queryValues.Set("full", "true")
+	}
+	if params.Next != "" {
+		queryValues.Set("next", params.Next)
+	}
+
+	body, err := c.budgetedGet(ctx, apiURL, queryValues, params.Size)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		SearchNextResponse
+		Results rawRows `json:"results"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
 		return nil, fmt.Errorf("解析响应失败: %w", err)
 	}
 
-	if searchResp.Error {
-		return nil, fmt.Errorf("FOFA API错误: %s", searchResp.ErrMsg)
+	if raw.Error {
+		return nil, apiError(raw.ErrMsg)
 	}
 
-	return &searchResp, nil
+	nextResp := raw.SearchNextResponse
+	nextResp.Results = zipRecords(fields, raw.Results)
+	return &nextResp, nil
 }
 
 // 获取统计信息
-func (c *FofaClient) Stats(query string, fields string) (*StatsResponse, error) {
+func (c *FofaClient) Stats(ctx context.Context, query string, fields string) (*StatsResponse, error) {
 	apiURL := fmt.Sprintf("%s/api/v1/search/stats", c.BaseURL)
 
 	queryBase64 := c.encodeQuery(query)
 
 	queryValues := url.Values{}
-	queryValues.Set("email", c.Email)
-	queryValues.Set("key", c.Key)
 	queryValues.Set("qbase64", queryBase64)
 	if fields != "" {
 		queryValues.Set("fields", fields)
 	}
 
-	fullURL := fmt.Sprintf("%s?%s", apiURL, queryValues.Encode())
-
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	req.Header.Set("User-Agent", "fofa-mcp/1.0")
-
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	key := cache.Key("fofa", "stats", c.cacheTier(ctx), normalizeQuery(query), ParseFields(fields).String())
+	body, err := c.Cache.Fetch(ctx, key, func() ([]byte, error) {
+		return c.get(ctx, apiURL, queryValues)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	var statsResp StatsResponse
@@ -203,42 +462,24 @@ func (c *FofaClient) Stats(query string, fields string) (*StatsResponse, error)
 	}
 
 	if statsResp.Error {
-		return nil, fmt.Errorf("FOFA API错误: %s", statsResp.ErrMsg)
+		return nil, apiError(statsResp.ErrMsg)
 	}
 
 	return &statsResp, nil
 }
 
 // 获取主机信息
-func (c *FofaClient) GetHostInfo(host string) (*HostInfoResponse, error) {
+func (c *FofaClient) GetHostInfo(ctx context.Context, host string) (*HostInfoResponse, error) {
 	apiURL := fmt.Sprintf("%s/api/v1/host/%s", c.BaseURL, url.QueryEscape(host))
 
 	queryValues := url.Values{}
-	queryValues.Set("email", c.Email)
-	queryValues.Set("key", c.Key)
-
-	fullURL := fmt.Sprintf("%s?%s", apiURL, queryValues.Encode())
-
-	req, err := http.NewRequest("GET", fullURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
-	}
-
-	req.Header.Set("User-Agent", "fofa-mcp/1.0")
 
-	resp, err := c.Client.Do(req)
+	key := cache.Key("fofa", "host", c.cacheTier(ctx), strings.ToLower(strings.TrimSpace(host)))
+	body, err := c.Cache.Fetch(ctx, key, func() ([]byte, error) {
+		return c.get(ctx, apiURL, queryValues)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	var hostResp HostInfoResponse
@@ -252,7 +493,7 @@ func (c *FofaClient) GetHostInfo(host string) (*HostInfoResponse, error) {
 		if msg, ok := hostResp["errmsg"].(string); ok {
 			errMsg = msg
 		}
-		return nil, fmt.Errorf("FOFA API错误: %s", errMsg)
+		return nil, apiError(errMsg)
 	}
 
 	return &hostResp, nil
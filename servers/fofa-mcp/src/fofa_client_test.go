@@ -0,0 +1,298 @@
+package src
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/httpx"
+	"securitymcp-hub/pkg/keypool"
+)
+
+func TestAPIErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		want   error
+	}{
+		{"凭证无效", http.StatusOK, `{"error":true,"errmsg":"[-700] Account Invalid"}`, httpx.ErrAuth},
+		{"F点不足", http.StatusOK, `{"error":true,"errmsg":"[820031] F点余额不足"}`, httpx.ErrQuota},
+		{"查询语句错误", http.StatusOK, `{"error":true,"errmsg":"[820000] query syntax error"}`, httpx.ErrBadQuery},
+		{"HTTP 401", http.StatusUnauthorized, `unauthorized`, httpx.ErrAuth},
+		{"HTTP 429", http.StatusTooManyRequests, `slow down`, httpx.ErrRateLimit},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				fmt.Fprint(w, tc.body)
+			}))
+			defer ts.Close()
+
+			client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{BaseURL: ts.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Search(context.Background(), QueryParams{Query: `app="test"`})
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("错误为 %v，期望 %v 类型", err, tc.want.(*httpx.APIError).Kind)
+			}
+		})
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"error":false,"size":1,"results":[["a.com","1.2.3.4","80","http"]]}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{BaseURL: ts.URL, MaxRetries: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Search(context.Background(), QueryParams{Query: `app="test"`})
+	if err != nil || calls != 2 || len(resp.Results) != 1 {
+		t.Fatalf("请求 %d 次，err=%v", calls, err)
+	}
+}
+
+func TestSearchCache(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/info/my" {
+			fmt.Fprint(w, `{"error":false,"vip_level":12}`)
+			return
+		}
+		calls++
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"error":true,"errmsg":"[820000] query syntax error"}`)
+			return
+		}
+		fmt.Fprint(w, `{"error":false,"size":1,"results":[["a.com","1.2.3.4","80","http"]]}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{
+		BaseURL: ts.URL, CacheBackend: cache.BackendMemory, CacheTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 只有空白和引号不同的查询共用缓存
+	for _, query := range []string{`app="test"`, ` app = test `} {
+		ctx, status := cache.WithMode(context.Background(), cache.ModeDefault)
+		if _, err := client.Search(ctx, QueryParams{Query: query}); err != nil {
+			t.Fatal(err)
+		}
+		if result := status.Result(); result.Hit != (query != `app="test"`) {
+			t.Errorf("%s: 缓存命中情况为 %+v", query, result)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("请求 %d 次，期望 1 次", calls)
+	}
+
+	// 错误响应不会被缓存
+	for i := 0; i < 2; i++ {
+		if _, err := client.Search(context.Background(), QueryParams{Query: `app="test"`, Page: 2}); err == nil {
+			t.Fatal("期望返回错误")
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("请求 %d 次，期望 3 次", calls)
+	}
+
+	ctx, _ := cache.WithMode(context.Background(), cache.ModeOnly)
+	if _, err := client.Search(ctx, QueryParams{Query: `app="other"`}); !errors.Is(err, cache.ErrMiss) {
+		t.Fatalf("cache=only 未命中时错误为 %v", err)
+	}
+
+	// 会员等级不同的账号不共用缓存
+	tier := TierEnterprise
+	client.AccountTier = &tier
+	if _, err := client.Search(context.Background(), QueryParams{Query: `app="test"`}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 {
+		t.Fatalf("请求 %d 次，期望其他会员等级的查询不命中缓存", calls)
+	}
+}
+
+func TestSearchBudget(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/info/my" {
+			fmt.Fprint(w, `{"error":false,"vip_level":1,"remain_api_data":0}`)
+			return
+		}
+		calls++
+		fmt.Fprint(w, `{"error":false,"size":100,"consumed_fpoint":2,"results":[["a.com","1.2.3.4","80","http"],["b.com","1.2.3.5","80","http"]]}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{
+		BaseURL: ts.URL, DailyBudget: budget.Limits{Calls: 2, Points: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 预算在自动翻页中途用尽时返回已取回的结果
+	result, err := client.SearchAll(context.Background(), QueryParams{Query: `app="test"`, Size: 2}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !errors.Is(result.Stopped, budget.ErrExhausted) || len(result.Results) != 4 || !result.HasMore {
+		t.Fatalf("结果为 %+v", result)
+	}
+	report := client.Budget.Report()
+	if !report.Exhausted || *report.Daily.Remaining.Calls != 0 || *report.Daily.Remaining.Points != 6 {
+		t.Fatalf("预算为 %+v", report.Daily)
+	}
+
+	// 用尽后直接拒绝，不请求 API
+	if _, err := client.Search(context.Background(), QueryParams{Query: `app="test"`}); !errors.Is(err, budget.ErrExhausted) {
+		t.Fatalf("错误为 %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("请求 %d 次，期望 2 次", calls)
+	}
+}
+
+func TestSearchPointsBudget(t *testing.T) {
+	calls, remain := 0, 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/info/my" {
+			fmt.Fprintf(w, `{"error":false,"vip_level":1,"remain_api_data":%d}`, remain)
+			return
+		}
+		calls++
+		fmt.Fprint(w, `{"error":false,"size":100,"consumed_fpoint":0,"results":[["a.com","1.2.3.4","80","http"]]}`)
+	}))
+	defer ts.Close()
+
+	newClient := func() *FofaClient {
+		client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{
+			BaseURL: ts.URL, DailyBudget: budget.Limits{Points: 50},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return client
+	}
+
+	// 免费数据条数不足时按每条结果 1 F点估算，可能超出剩余预算时直接拒绝，不请求搜索接口
+	client := newClient()
+	if _, err := client.Search(context.Background(), QueryParams{Query: `app="test"`, Size: 100}); !errors.Is(err, budget.ErrExhausted) {
+		t.Fatalf("错误为 %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("请求 %d 次，期望 0 次", calls)
+	}
+
+	// 免费数据条数足够时不预留F点，按 consumed_fpoint 结算
+	remain = 10000
+	client = newClient()
+	if _, err := client.Search(context.Background(), QueryParams{Query: `app="test"`, Size: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if report := client.Budget.Report(); calls != 1 || *report.Daily.Remaining.Points != 50 {
+		t.Fatalf("请求 %d 次，预算为 %+v", calls, report.Daily)
+	}
+}
+
+func TestKeyFailover(t *testing.T) {
+	var usedKeys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Query().Get("key")
+		usedKeys = append(usedKeys, key)
+		if key == "drained" {
+			fmt.Fprint(w, `{"error":true,"errmsg":"[820031] F点余额不足"}`)
+			return
+		}
+		fmt.Fprint(w, `{"error":false,"size":1,"results":[["a.com","1.2.3.4","80","http"]]}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithKeys([]keypool.Key{
+		{Label: "main", User: "a@example.com", Secret: "drained"},
+		{Label: "backup", User: "b@example.com", Secret: "ok"},
+	}, ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, usage := keypool.Track(context.Background())
+		if _, err := client.Search(ctx, QueryParams{Query: `app="test"`}); err != nil {
+			t.Fatal(err)
+		}
+		if result := usage.Result(); len(result.ServedBy) != 1 || result.ServedBy[0] != "backup" {
+			t.Fatalf("第 %d 次查询使用的 key 为 %+v", i+1, result)
+		}
+	}
+	// 额度不足的 key 被暂停后不再使用
+	if fmt.Sprint(usedKeys) != "[drained ok ok]" {
+		t.Fatalf("请求使用的 key 依次为 %v", usedKeys)
+	}
+}
+
+func TestNoCredentialsInErrors(t *testing.T) {
+	const email, secret = "leak@example.com", "fofa-secret-key-0123456789"
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		closed  bool
+	}{
+		{"响应中回显请求地址", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, r.URL.String())
+		}, false},
+		{"错误信息中包含 key", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"error":true,"errmsg":"[-700] Account Invalid: %s"}`, r.URL.Query().Get("key"))
+		}, false},
+		{"连接失败", nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(tc.handler)
+			if tc.closed {
+				ts.Close()
+			} else {
+				defer ts.Close()
+			}
+
+			client, err := NewFofaClientWithConfig(email, secret, ClientConfig{BaseURL: ts.URL, Timeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.Search(context.Background(), QueryParams{Query: `app="test"`})
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			for _, leaked := range []string{secret, email, url.QueryEscape(email)} {
+				if strings.Contains(err.Error(), leaked) {
+					t.Fatalf("错误信息中包含凭证: %v", err)
+				}
+			}
+		})
+	}
+}
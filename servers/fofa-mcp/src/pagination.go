@@ -0,0 +1,160 @@
+package src
+
+import (
+	"context"
+	"errors"
+
+	"securitymcp-hub/pkg/budget"
+)
+
+// 自动翻页的结果汇总
+type SearchAllResult struct {
+	Query          string   // 实际执行的查询语句
+	Mode           string   // 查询模式
+	StartPage      int      // 起始页码
+	PageSize       int      // 每页数量
+	Total          int      // 查询结果总数
+	Pages          int      // 实际请求的页数
+	ConsumedFpoint int      // 所有页消耗的F点合计
+	HasMore        bool     // 是否还有未取回的结果
+	Stopped        error    // 用量预算用尽导致提前结束时的原因，此时 Results 只包含已取回的部分
+	Results        []Record // 取回的结果，不超过 maxResults 条
+}
+
+// 搜索结果迭代器，逐页请求直到取满目标数量或没有更多结果。用法：
+//
+//	it := client.SearchPages(params, 500)
+//	for it.Next(ctx) {
+//		page := it.Page()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type SearchIterator struct {
+	client     *FofaClient
+	params     QueryParams
+	maxResults int
+	offset     int // 起始页之前跳过的结果数量
+
+	page     *SearchResponse
+	err      error
+	done     bool
+	fetched  int
+	pages    int
+	consumed int
+	total    int
+}
+
+// 创建搜索结果迭代器。maxResults 为最多取回的结果数量，小于1时只请求一页；
+// params.Size 为每页数量，未设置时按 maxResults 和字段对应的单页上限（cert/banner 为2000，其他为10000）选择
+func (c *FofaClient) SearchPages(params QueryParams, maxResults int) *SearchIterator {
+	if params.Page < 1 {
+		params.Page = 1
+	}
+	if params.Size < 1 {
+		params.Size = maxResults
+		if params.Size < 1 {
+			params.Size = 100
+		}
+	}
+	params.Size, _ = ParseFields(params.Fields).ClampSize(params.Size)
+	if maxResults < 1 {
+		maxResults = params.Size
+	}
+
+	return &SearchIterator{
+		client:     c,
+		params:     params,
+		maxResults: maxResults,
+		offset:     (params.Page - 1) * params.Size,
+	}
+}
+
+// 请求下一页，没有更多结果或出错时返回 false
+func (it *SearchIterator) Next(ctx context.Context) bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	resp, err := it.client.Search(ctx, it.params)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.pages++
+	it.consumed += resp.ConsumedFpoint
+	it.total = resp.Size
+
+	// 最后一页只保留目标数量以内的结果
+	if remaining := it.maxResults - it.fetched; len(resp.Results) > remaining {
+		resp.Results = resp.Results[:remaining]
+	}
+	it.fetched += len(resp.Results)
+	it.page = resp
+
+	// 取满目标数量、本页不足一整页或已达结果总数时结束
+	if it.fetched >= it.maxResults || len(resp.Results) < it.params.Size ||
+		it.offset+it.fetched >= resp.Size {
+		it.done = true
+	}
+	it.params.Page++
+
+	return len(resp.Results) > 0 || it.pages == 1
+}
+
+// 当前页的响应
+func (it *SearchIterator) Page() *SearchResponse {
+	return it.page
+}
+
+// 迭代过程中的错误
+func (it *SearchIterator) Err() error {
+	return it.err
+}
+
+// 已请求的页数
+func (it *SearchIterator) Pages() int {
+	return it.pages
+}
+
+// 已消耗的F点合计
+func (it *SearchIterator) ConsumedFpoint() int {
+	return it.consumed
+}
+
+// 是否还有未取回的结果
+func (it *SearchIterator) HasMore() bool {
+	return it.pages > 0 && it.offset+it.fetched < it.total
+}
+
+// 自动翻页取回最多 maxResults 条结果
+func (c *FofaClient) SearchAll(ctx context.Context, params QueryParams, maxResults int) (*SearchAllResult, error) {
+	it := c.SearchPages(params, maxResults)
+	result := &SearchAllResult{
+		StartPage: it.params.Page,
+		PageSize:  it.params.Size,
+		Results:   []Record{},
+	}
+
+	for it.Next(ctx) {
+		page := it.Page()
+		result.Query = page.Query
+		result.Mode = page.Mode
+		result.Total = page.Size
+		result.Results = append(result.Results, page.Results...)
+	}
+	if err := it.Err(); err != nil {
+		// 已取回部分结果时预算用尽，返回已取回的结果
+		if !errors.Is(err, budget.ErrExhausted) || it.Pages() == 0 {
+			return nil, err
+		}
+		result.Stopped = err
+	}
+
+	result.Pages = it.Pages()
+	result.ConsumedFpoint = it.ConsumedFpoint()
+	result.HasMore = it.HasMore()
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package src
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// 模拟 FOFA 搜索接口：共 total 条结果，每次请求消耗 1 F点
+func newFakeFofa(t *testing.T, total int, requests *[]string) *FofaClient {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		*requests = append(*requests, fmt.Sprintf("%d/%d", page, size))
+
+		results := [][]string{}
+		for i := (page - 1) * size; i < page*size && i < total; i++ {
+			results = append(results, []string{fmt.Sprintf("host%d", i)})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"size":            total,
+			"page":            page,
+			"mode":            "extended",
+			"query":           "app=\"test\"",
+			"results":         results,
+			"consumed_fpoint": 1,
+		})
+	}))
+	t.Cleanup(ts.Close)
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSearchAll(t *testing.T) {
+	cases := []struct {
+		name       string
+		total      int
+		params     QueryParams
+		maxResults int
+		requests   []string
+		results    int
+		hasMore    bool
+	}{
+		{"单页", 500, QueryParams{}, 0, []string{"1/100"}, 100, true},
+		{"取满目标数量", 500, QueryParams{Size: 100}, 250, []string{"1/100", "2/100", "3/100"}, 250, true},
+		{"结果不足", 150, QueryParams{Size: 100}, 1000, []string{"1/100", "2/100"}, 150, false},
+		{"恰好取完", 200, QueryParams{Size: 100}, 1000, []string{"1/100", "2/100"}, 200, false},
+		{"按目标数量选择每页数量", 5000, QueryParams{}, 3000, []string{"1/3000"}, 3000, true},
+		{"cert字段单页上限", 5000, QueryParams{Fields: "ip,cert"}, 3000, []string{"1/2000", "2/2000"}, 3000, true},
+		{"从指定页开始", 500, QueryParams{Page: 4, Size: 100}, 1000, []string{"4/100", "5/100"}, 200, false},
+		{"没有结果", 0, QueryParams{}, 100, []string{"1/100"}, 0, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests []string
+			client := newFakeFofa(t, tc.total, &requests)
+
+			result, err := client.SearchAll(context.Background(), tc.params, tc.maxResults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(requests) != fmt.Sprint(tc.requests) {
+				t.Errorf("请求页 %v，期望 %v", requests, tc.requests)
+			}
+			if len(result.Results) != tc.results {
+				t.Errorf("结果 %d 条，期望 %d 条", len(result.Results), tc.results)
+			}
+			if result.Pages != len(tc.requests) || result.ConsumedFpoint != len(tc.requests) {
+				t.Errorf("页数 %d、F点 %d，期望均为 %d", result.Pages, result.ConsumedFpoint, len(tc.requests))
+			}
+			if result.HasMore != tc.hasMore {
+				t.Errorf("has_more 为 %v，期望 %v", result.HasMore, tc.hasMore)
+			}
+			if result.Total != tc.total {
+				t.Errorf("总数 %d，期望 %d", result.Total, tc.total)
+			}
+		})
+	}
+}
+
+func TestSearchIteratorStopsOnError(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls > 1 {
+			json.NewEncoder(w).Encode(SearchResponse{Error: true, ErrMsg: "F点余额不足"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"size": 300, "results": make([][]string, 100)})
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	it := client.SearchPages(QueryParams{Size: 100}, 300)
+	pages := 0
+	for it.Next(context.Background()) {
+		pages++
+	}
+	if pages != 1 || it.Err() == nil {
+		t.Fatalf("迭代 %d 页，错误 %v；期望第二页出错后停止", pages, it.Err())
+	}
+	if _, err := client.SearchAll(context.Background(), QueryParams{Size: 100}, 300); err == nil {
+		t.Fatal("SearchAll 应返回翻页过程中的错误")
+	}
+}
+
+func TestSearchNextCursor(t *testing.T) {
+	// 游标为下一条结果的序号，共 250 条
+	const total = 250
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/search/next" {
+			t.Errorf("请求路径 %s", r.URL.Path)
+		}
+		start, _ := strconv.Atoi(r.URL.Query().Get("next"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+
+		// 只请求一个字段时 FOFA 返回一维数组
+		results := []string{}
+		for i := start; i < start+size && i < total; i++ {
+			results = append(results, strconv.Itoa(i))
+		}
+		next := ""
+		if start+size < total {
+			next = strconv.Itoa(start + size)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"size": total, "results": results, "next": next})
+	}))
+	defer ts.Close()
+
+	client, err := NewFofaClientWithConfig("user@example.com", "key", ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	params := SearchNextParams{Query: "app=\"test\"", Size: 100, Fields: "host"}
+	for pages := 1; ; pages++ {
+		resp, err := client.SearchNext(context.Background(), params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range resp.Results {
+			host := record["host"].(string)
+			if seen[host] {
+				t.Fatalf("第 %d 页出现重复结果 %s", pages, host)
+			}
+			seen[host] = true
+		}
+		if resp.Next == "" {
+			if pages != 3 {
+				t.Fatalf("翻页 %d 次，期望 3 次", pages)
+			}
+			break
+		}
+		params.Next = resp.Next
+	}
+	if len(seen) != total {
+		t.Fatalf("共取回 %d 条，期望 %d 条", len(seen), total)
+	}
+}
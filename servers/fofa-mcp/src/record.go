@@ -0,0 +1,108 @@
+package src
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 单条搜索结果，键为请求的字段名
+type Record map[string]interface{}
+
+// FOFA 时间字段使用北京时间，不带时区
+var fofaLocation = time.FixedZone("CST", 8*60*60)
+
+// FOFA 时间字段可能出现的格式
+var fofaTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02",
+}
+
+// 需要类型转换的字段
+var (
+	intFields   = map[string]bool{"port": true}
+	floatFields = map[string]bool{"latitude": true, "longitude": true}
+	timeFields  = map[string]bool{"lastupdatetime": true, "cert.not_before": true, "cert.not_after": true}
+)
+
+// API 返回的原始结果行。只请求一个字段时 FOFA 返回一维数组，
+// 解析时统一转换为每行一个值的二维数组
+type rawRows [][]interface{}
+
+func (r *rawRows) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+
+	rows := make(rawRows, 0, len(items))
+	for _, item := range items {
+		if bytes.HasPrefix(bytes.TrimSpace(item), []byte("[")) {
+			var row []interface{}
+			if err := json.Unmarshal(item, &row); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+			continue
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(item, &value); err != nil {
+			return err
+		}
+		rows = append(rows, []interface{}{value})
+	}
+	*r = rows
+	return nil
+}
+
+// 按请求的字段顺序把结果行转换为记录，缺失的字段值为 nil
+func zipRecords(fields FieldList, rows rawRows) []Record {
+	records := make([]Record, 0, len(rows))
+	for _, row := range rows {
+		record := make(Record, len(fields))
+		for i, field := range fields {
+			var value interface{}
+			if i < len(row) {
+				value = convertField(field, row[i])
+			}
+			record[field] = value
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+// 转换端口、经纬度和时间字段的类型，空值转换为 nil，无法转换时保留原值
+func convertField(field string, value interface{}) interface{} {
+	if !intFields[field] && !floatFields[field] && !timeFields[field] {
+		return value
+	}
+
+	text := strings.TrimSpace(fmt.Sprint(value))
+	if value == nil || text == "" {
+		return nil
+	}
+
+	switch {
+	case intFields[field]:
+		if n, err := strconv.Atoi(text); err == nil {
+			return n
+		}
+	case floatFields[field]:
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return f
+		}
+	case timeFields[field]:
+		for _, layout := range fofaTimeLayouts {
+			if t, err := time.ParseInLocation(layout, text, fofaLocation); err == nil {
+				return t
+			}
+		}
+	}
+	return value
+}
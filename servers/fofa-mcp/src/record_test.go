@@ -0,0 +1,71 @@
+package src
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestZipRecords(t *testing.T) {
+	var rows rawRows
+	body := `[["1.2.3.4","443","https","39.9","116.4","2023-08-24 12:00:00"],["5.6.7.8","","http","","",""]]`
+	if err := json.Unmarshal([]byte(body), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	records := zipRecords(ParseFields("ip, port,protocol,latitude,longitude,lastupdatetime"), rows)
+	want := []Record{
+		{
+			"ip":             "1.2.3.4",
+			"port":           443,
+			"protocol":       "https",
+			"latitude":       39.9,
+			"longitude":      116.4,
+			"lastupdatetime": time.Date(2023, 8, 24, 12, 0, 0, 0, fofaLocation),
+		},
+		{
+			"ip":             "5.6.7.8",
+			"port":           nil,
+			"protocol":       "http",
+			"latitude":       nil,
+			"longitude":      nil,
+			"lastupdatetime": nil,
+		},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("记录不符:\n%v\n期望:\n%v", records, want)
+	}
+
+	data, err := json.Marshal(records[0]["lastupdatetime"])
+	if err != nil || string(data) != `"2023-08-24T12:00:00+08:00"` {
+		t.Fatalf("时间字段序列化为 %s, %v", data, err)
+	}
+}
+
+func TestZipRecordsSingleField(t *testing.T) {
+	// 只请求一个字段时 FOFA 返回一维数组
+	var rows rawRows
+	if err := json.Unmarshal([]byte(`["80","443","not-a-port"]`), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	records := zipRecords(ParseFields("port"), rows)
+	want := []Record{{"port": 80}, {"port": 443}, {"port": "not-a-port"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("记录不符: %v", records)
+	}
+}
+
+func TestZipRecordsShortRow(t *testing.T) {
+	var rows rawRows
+	if err := json.Unmarshal([]byte(`[["1.2.3.4"]]`), &rows); err != nil {
+		t.Fatal(err)
+	}
+
+	records := zipRecords(ParseFields("ip,port"), rows)
+	want := []Record{{"ip": "1.2.3.4", "port": nil}}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("缺失字段应为 nil: %v", records)
+	}
+}
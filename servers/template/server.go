@@ -1,134 +1,44 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
-	"fmt"
+	"context"
+	"flag"
 	"log"
 	"os"
-)
-
-// MCP请求结构
-type MCPRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	ID      interface{}     `json:"id"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params,omitempty"`
-}
-
-// MCP响应结构
-type MCPResponse struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id"`
-	Result  interface{} `json:"result,omitempty"`
-	Error   *MCPError   `json:"error,omitempty"`
-}
-
-type MCPError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
 
-// 工具定义
-type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	InputSchema map[string]interface{} `json:"inputSchema"`
-}
-
-// 调用工具结果
-type CallToolResult struct {
-	Content []map[string]interface{} `json:"content"`
-	IsError bool                     `json:"isError,omitempty"`
-}
+	"securitymcp-hub/pkg/mcp"
+	"securitymcp-hub/pkg/redact"
+)
 
 func main() {
-	// 从环境变量获取配置
-	// TODO: 添加你的配置读取逻辑
-
-	// 使用标准输入输出进行JSON-RPC通信
-	scanner := bufio.NewScanner(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+	// 日志中的凭证替换为 [REDACTED]
+	log.SetOutput(redact.Writer(os.Stderr))
 
-	for scanner.Scan() {
-		var request MCPRequest
-		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
-			sendError(encoder, nil, -32700, "Parse error", err.Error())
-			continue
-		}
+	flags := mcp.RegisterFlags(flag.CommandLine)
+	flag.Parse()
 
-		var response MCPResponse
-		response.JSONRPC = "2.0"
-		response.ID = request.ID
-
-		switch request.Method {
-		case "initialize":
-			response.Result = map[string]interface{}{
-				"protocolVersion": "2024-11-05",
-				"capabilities": map[string]interface{}{
-					"tools": map[string]interface{}{},
-				},
-				"serverInfo": map[string]interface{}{
-					"name":    "your-service-mcp",
-					"version": "1.0.0",
-				},
-			}
-
-		case "tools/list":
-			// TODO: 注册你的工具
-			response.Result = map[string]interface{}{
-				"tools": []Tool{},
-			}
-
-		case "tools/call":
-			// TODO: 处理工具调用
-			var callRequest struct {
-				Name      string                 `json:"name"`
-				Arguments map[string]interface{} `json:"arguments"`
-			}
-			if err := json.Unmarshal(request.Params, &callRequest); err != nil {
-				sendError(encoder, request.ID, -32602, "Invalid params", err.Error())
-				continue
-			}
-
-			// TODO: 实现工具处理逻辑
-			result := CallToolResult{
-				Content: []map[string]interface{}{
-					{
-						"type": "text",
-						"text": "Not implemented yet",
-					},
-				},
-			}
-			response.Result = result
-
-		default:
-			sendError(encoder, request.ID, -32601, "Method not found", fmt.Sprintf("Unknown method: %s", request.Method))
-			continue
-		}
+	// 从环境变量获取配置
+	// TODO: 添加你的配置读取逻辑；API Key 等凭证应通过 redact.Add 登记，以便从错误、日志和工具结果中替换
 
-		if err := encoder.Encode(response); err != nil {
-			log.Printf("编码响应失败: %v", err)
-		}
-	}
+	server := mcp.NewServer("your-service-mcp", "1.0.0")
+	registerTools(server)
 
-	if err := scanner.Err(); err != nil {
+	// 默认使用标准输入输出进行JSON-RPC通信，指定 -http 时提供 Streamable HTTP 服务
+	if err := server.Run(flags); err != nil {
 		log.Fatal(err)
 	}
 }
 
-func sendError(encoder *json.Encoder, id interface{}, code int, message, data string) {
-	response := MCPResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Error: &MCPError{
-			Code:    code,
-			Message: message,
+// 注册工具
+func registerTools(server *mcp.Server) {
+	// TODO: 注册你的工具
+	server.RegisterTool("your_tool", "工具描述",
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
 		},
-	}
-	if data != "" {
-		response.Error.Message = fmt.Sprintf("%s: %s", message, data)
-	}
-	encoder.Encode(response)
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			// TODO: 实现工具处理逻辑
+			return mcp.TextResult("Not implemented yet"), nil
+		})
 }
-
@@ -1,115 +1,101 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/base64"
-	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/dork"
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/mcp"
+	"securitymcp-hub/pkg/redact"
+	"securitymcp-hub/pkg/secrets"
 	"zoomeye-mcp/src"
 )
 
-// MCP请求结构
-type MCPRequest struct {
-	JSONRPC string          `json:"jsonrpc"`
-	ID      interface{}     `json:"id"`
-	Method  string          `json:"method"`
-	Params  json.RawMessage `json:"params,omitempty"`
-}
-
-// MCP响应结构
-type MCPResponse struct {
-	JSONRPC string      `json:"jsonrpc"`
-	ID      interface{} `json:"id"`
-	Result  interface{} `json:"result,omitempty"`
-	Error   *MCPError   `json:"error,omitempty"`
-}
-
-type MCPError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
-
-// 工具定义
-type Tool struct {
-	Name        string                 `json:"name"`
-	Description string                 `json:"description"`
-	InputSchema map[string]interface{} `json:"inputSchema"`
-}
-
-// 调用工具请求
-type CallToolRequest struct {
-	Name      string                 `json:"name"`
-	Arguments map[string]interface{} `json:"arguments"`
-}
+func main() {
+	// 日志中的凭证替换为 [REDACTED]
+	log.SetOutput(redact.Writer(os.Stderr))
 
-// 调用工具结果
-type CallToolResult struct {
-	Content []map[string]interface{} `json:"content"`
-	IsError bool                     `json:"isError,omitempty"`
-}
+	flags := mcp.RegisterFlags(flag.CommandLine)
+	configPath := flag.String("config", os.Getenv("ZOOMEYE_MCP_CONFIG"), "配置文件路径（YAML），也可通过环境变量 ZOOMEYE_MCP_CONFIG 设置")
+	flag.Parse()
 
-func main() {
-	// 从环境变量获取 ZoomEye API Key
-	apiKey := os.Getenv("ZOOMEYE_API_KEY")
+	cfg, err := src.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("加载配置失败: %v", err)
+	}
 
-	if apiKey == "" {
-		log.Fatal("请设置环境变量 ZOOMEYE_API_KEY")
+	// 获取 ZoomEye API Key
+	keys, err := loadKeys(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(keys) == 0 {
+		log.Fatal("请设置环境变量 ZOOMEYE_API_KEY，或通过 ZOOMEYE_API_KEYS 设置多个 API Key；也可以使用 _FILE 文件、加密凭证文件或辅助命令")
 	}
 
 	// 创建 ZoomEye 客户端
-	zoomeyeClient := src.NewZoomEyeClient(apiKey)
-
-	// 使用标准输入输出进行JSON-RPC通信
-	scanner := bufio.NewScanner(os.Stdin)
-	encoder := json.NewEncoder(os.Stdout)
+	zoomeyeClient, err := src.NewZoomEyeClientWithKeys(keys, cfg.ClientConfig())
+	if err != nil {
+		log.Fatalf("创建 ZoomEye 客户端失败: %v", err)
+	}
 
-	for scanner.Scan() {
-		var request MCPRequest
-		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
-			sendError(encoder, nil, -32700, "Parse error", err.Error())
-			continue
+	// 收到 SIGHUP 时重新读取凭证，失败时继续使用原有凭证
+	secrets.OnReload(func() {
+		keys, err := loadKeys(cfg)
+		if err == nil {
+			err = zoomeyeClient.Keys.Replace(keys)
 		}
+		if err != nil {
+			log.Printf("重新加载 ZoomEye 凭证失败，继续使用原有凭证: %v", err)
+			return
+		}
+		log.Printf("已重新加载 %d 个 ZoomEye API Key", len(keys))
+	})
 
-		var response MCPResponse
-		response.JSONRPC = "2.0"
-		response.ID = request.ID
+	server := mcp.NewServer(cfg.Server.Name, cfg.Server.Version)
+	registerTools(server, zoomeyeClient)
 
-		switch request.Method {
-		case "initialize":
-			response.Result = map[string]interface{}{
-				"protocolVersion": "2024-11-05",
-				"capabilities": map[string]interface{}{
-					"tools": map[string]interface{}{},
-				},
-				"serverInfo": map[string]interface{}{
-					"name":    "zoomeye-mcp",
-					"version": "1.0.0",
-				},
-			}
+	if err := server.Run(flags); err != nil {
+		log.Fatal(err)
+	}
+}
 
-		case "tools/list":
-			response.Result = map[string]interface{}{
-				"tools": []Tool{
-					{
-						Name: "zoomeye_userinfo",
-						Description: `获取 ZoomEye 用户信息，包括用户名、邮箱、订阅计划、积分等详细信息。
+// 按配置的凭证来源读取 API Key
+func loadKeys(cfg *src.Config) ([]keypool.Key, error) {
+	store, err := secrets.Open(cfg.Secrets.Options())
+	if err != nil {
+		return nil, err
+	}
+	return src.LoadKeys(context.Background(), store)
+}
+
+// 注册 ZoomEye 工具
+func registerTools(server *mcp.Server, client *src.ZoomEyeClient) {
+	server.RegisterTool("zoomeye_userinfo", `获取 ZoomEye 用户信息，包括用户名、邮箱、订阅计划、积分等详细信息。
 
 返回信息包括：
 - 用户基本信息（用户名、邮箱、电话、创建时间）
 - 订阅信息（计划类型、结束日期、普通积分、权益积分）
 
 可用于查询当前账号状态和可用积分。`,
-						InputSchema: map[string]interface{}{
-							"type":       "object",
-							"properties": map[string]interface{}{},
-						},
-					},
-					{
-						Name: "zoomeye_search",
-						Description: `在 ZoomEye 中搜索网络资产。支持自定义查询语句、分页、返回字段等所有参数。所有参数都可以由大模型自主配置。
+		map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleZoomEyeUserInfo(ctx, client)
+		},
+		mcp.WithOutputSchema(zoomEyeUserInfoOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "ZoomEye 用户信息", ReadOnlyHint: true, OpenWorldHint: true}))
+
+	server.RegisterTool("zoomeye_search", `在 ZoomEye 中搜索网络资产。支持自定义查询语句、分页、返回字段等所有参数。所有参数都可以由大模型自主配置。
 
 支持的功能：
 - 自定义查询语句（会自动进行 Base64 编码）
@@ -128,26 +114,26 @@ func main() {
 - 其他：iconhash_md5, robots_md5, security_md5, idc, honeypot, primary_industry, sub_industry, rank
 
 字段权限取决于 ZoomEye 账号版本（免费版、专业版、商业版等）。`,
-						InputSchema: map[string]interface{}{
-							"type": "object",
-							"properties": map[string]interface{}{
-								"query": map[string]interface{}{
-									"type":        "string",
-									"description": "ZoomEye 查询语句，例如：title=\"cisco vpn\" 或 app=\"nginx\" && country=\"CN\"。查询语句会自动进行 Base64 编码，可以根据需要构建任意查询语句",
-								},
-								"page": map[string]interface{}{
-									"type":        "integer",
-									"description": "页码，从1开始，默认为1。可以根据需要设置任意页码进行翻页",
-									"default":     1,
-								},
-								"pagesize": map[string]interface{}{
-									"type":        "integer",
-									"description": "每页返回数量，范围1-10000，默认为10。可以根据需要设置任意数量",
-									"default":     10,
-								},
-								"fields": map[string]interface{}{
-									"type": "string",
-									"description": `返回字段，逗号分隔，例如：ip,port,domain,update_time。支持所有 ZoomEye API 字段，可根据需要选择任意字段组合。
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "ZoomEye 查询语句，例如：title=\"cisco vpn\" 或 app=\"nginx\" && country=\"CN\"。查询语句会自动进行 Base64 编码，可以根据需要构建任意查询语句",
+				},
+				"page": map[string]interface{}{
+					"type":        "integer",
+					"description": "页码，从1开始，默认为1。可以根据需要设置任意页码进行翻页",
+					"default":     1,
+				},
+				"pagesize": map[string]interface{}{
+					"type":        "integer",
+					"description": "每页返回数量，范围1-10000，默认为10。可以根据需要设置任意数量",
+					"default":     10,
+				},
+				"fields": map[string]interface{}{
+					"type": "string",
+					"description": `返回字段，逗号分隔，例如：ip,port,domain,update_time。支持所有 ZoomEye API 字段，可根据需要选择任意字段组合。
 
 常用字段：
 - 基础：ip, port, domain, url, hostname, os, service, title, version, device, rdns, product, banner, update_time
@@ -158,134 +144,192 @@ func main() {
 - 其他：iconhash_md5, robots_md5, security_md5, idc, honeypot, primary_industry, sub_industry, rank
 
 字段权限取决于您的 ZoomEye 账号版本，超出权限的字段将返回空值。`,
-									"default": "ip,port,domain,update_time",
-								},
-								"sub_type": map[string]interface{}{
-									"type":        "string",
-									"description": "数据类型，支持 v4（IPv4）、v6（IPv6）和 web（Web资产），默认为 v4",
-									"enum":        []string{"v4", "v6", "web"},
-									"default":     "v4",
-								},
-								"facets": map[string]interface{}{
-									"type":        "string",
-									"description": "统计项，如果有多个，用逗号分隔。支持：country, subdivisions, city, product, service, device, os, port。例如：country,product,port",
-								},
-								"ignore_cache": map[string]interface{}{
-									"type":        "boolean",
-									"description": "是否忽略缓存，默认为 false。支持商业版及以上用户",
-									"default":     false,
-								},
-							},
-							"required": []string{"query"},
-						},
-					},
+					"default": "ip,port,domain,update_time",
 				},
-			}
-
-		case "tools/call":
-			var callRequest CallToolRequest
-			if err := json.Unmarshal(request.Params, &callRequest); err != nil {
-				sendError(encoder, request.ID, -32602, "Invalid params", err.Error())
-				continue
-			}
-
-			var result CallToolResult
-			var err error
-
-			switch callRequest.Name {
-			case "zoomeye_userinfo":
-				result, err = handleZoomEyeUserInfo(zoomeyeClient)
-			case "zoomeye_search":
-				result, err = handleZoomEyeSearch(zoomeyeClient, callRequest.Arguments)
-			default:
-				sendError(encoder, request.ID, -32601, "Method not found", fmt.Sprintf("Unknown tool: %s", callRequest.Name))
-				continue
-			}
-
-			if err != nil {
-				result = CallToolResult{
-					Content: []map[string]interface{}{
-						{
-							"type": "text",
-							"text": fmt.Sprintf("错误: %v", err),
-						},
-					},
-					IsError: true,
-				}
-			}
+				"sub_type": map[string]interface{}{
+					"type":        "string",
+					"description": "数据类型，支持 v4（IPv4）、v6（IPv6）和 web（Web资产），默认为 v4",
+					"enum":        []string{"v4", "v6", "web"},
+					"default":     "v4",
+				},
+				"facets": map[string]interface{}{
+					"type":        "string",
+					"description": "统计项，如果有多个，用逗号分隔。支持：country, subdivisions, city, product, service, device, os, port。例如：country,product,port",
+				},
+				"ignore_cache": map[string]interface{}{
+					"type":        "boolean",
+					"description": "是否忽略缓存，默认为 false。支持商业版及以上用户",
+					"default":     false,
+				},
+				"cache": cache.ArgumentSchema,
+			},
+			"required": []string{"query"},
+		},
+		func(ctx context.Context, args map[string]interface{}) (mcp.CallToolResult, error) {
+			return handleZoomEyeSearch(ctx, client, args)
+		},
+		mcp.WithOutputSchema(zoomEyeSearchOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "ZoomEye 资产搜索", ReadOnlyHint: true, OpenWorldHint: true}))
 
-			response.Result = result
+	server.RegisterTool("zoomeye_query_validate", `检查 ZoomEye 查询语句的语法，不会调用 ZoomEye API，也不消耗积分。
 
-		default:
-			sendError(encoder, request.ID, -32601, "Method not found", fmt.Sprintf("Unknown method: %s", request.Method))
-			continue
-		}
+支持的语法：key="value"、key=="value"（精确匹配）、key!="value"（排除）、&&、||、括号，以及单独的 "关键词" 全文搜索。
 
-		if err := encoder.Encode(response); err != nil {
-			log.Printf("编码响应失败: %v", err)
-		}
-	}
+语法正确时返回规范化的查询语句和语法树，并提示值格式可能不正确的条件；语法错误时返回出错位置和原因，例如未闭合的引号、使用 AND 代替 &&、旧版 key:value 语法、未知的查询字段等。zoomeye_search 在调用 API 前会进行同样的检查。`,
+		map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "要检查的 ZoomEye 查询语句",
+				},
+			},
+			"required": []string{"query"},
+		},
+		dork.ValidateHandler(dork.ZoomEye),
+		mcp.WithOutputSchema(dork.ValidateOutputSchema),
+		mcp.WithAnnotations(mcp.ToolAnnotations{Title: "ZoomEye 查询语法检查", ReadOnlyHint: true, IdempotentHint: true}))
 
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
-	}
+	dork.RegisterTranslateTool(server, dork.ZoomEye)
 }
 
-func sendError(encoder *json.Encoder, id interface{}, code int, message, data string) {
-	response := MCPResponse{
-		JSONRPC: "2.0",
-		ID:      id,
-		Error: &MCPError{
-			Code:    code,
-			Message: message,
-		},
-	}
-	if data != "" {
-		response.Error.Message = fmt.Sprintf("%s: %s", message, data)
-	}
-	encoder.Encode(response)
+// zoomeye_userinfo 结构化输出
+type zoomEyeUserInfoOutput struct {
+	Success bool            `json:"success"`
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    zoomEyeUserData `json:"data"`
+
+	Keys    *keypool.Result     `json:"keys"`
+	KeyPool []keypool.KeyStatus `json:"key_pool,omitempty"` // 配置了多个 API Key 时各 key 的状态
 }
 
-func handleZoomEyeUserInfo(client *src.ZoomEyeClient) (CallToolResult, error) {
-	result, err := client.GetUserInfo()
-	if err != nil {
-		return CallToolResult{}, err
-	}
+type zoomEyeUserData struct {
+	Username     string              `json:"username"`
+	Email        string              `json:"email"`
+	Phone        string              `json:"phone"`
+	CreatedAt    string              `json:"created_at"`
+	Subscription zoomEyeSubscription `json:"subscription"`
+}
+
+type zoomEyeSubscription struct {
+	Plan          string `json:"plan"`
+	EndDate       string `json:"end_date"`
+	Points        string `json:"points"`
+	ZoomEyePoints string `json:"zoomeye_points"`
+}
 
-	response := map[string]interface{}{
-		"success": true,
-		"code":    result.Code,
-		"message": result.Message,
+var zoomEyeUserInfoOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success": map[string]interface{}{"type": "boolean"},
+		"code":    map[string]interface{}{"type": "integer"},
+		"message": map[string]interface{}{"type": "string"},
 		"data": map[string]interface{}{
-			"username":   result.Data.Username,
-			"email":      result.Data.Email,
-			"phone":      result.Data.Phone,
-			"created_at": result.Data.CreatedAt,
-			"subscription": map[string]interface{}{
-				"plan":          result.Data.Subscription.Plan,
-				"end_date":      result.Data.Subscription.EndDate,
-				"points":        result.Data.Subscription.Points,
-				"zoomeye_points": result.Data.Subscription.ZoomEyePoints,
+			"type": "object",
+			"properties": map[string]interface{}{
+				"username":   map[string]interface{}{"type": "string"},
+				"email":      map[string]interface{}{"type": "string"},
+				"phone":      map[string]interface{}{"type": "string"},
+				"created_at": map[string]interface{}{"type": "string"},
+				"subscription": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"plan":           map[string]interface{}{"type": "string", "description": "订阅计划"},
+						"end_date":       map[string]interface{}{"type": "string", "description": "订阅结束日期"},
+						"points":         map[string]interface{}{"type": "string", "description": "普通积分"},
+						"zoomeye_points": map[string]interface{}{"type": "string", "description": "权益积分"},
+					},
+				},
 			},
 		},
-	}
+		"keys":     keypool.ResultSchema,
+		"key_pool": keypool.StatusSchema,
+	},
+	"required": []string{"success", "code", "data"},
+}
+
+// zoomeye_search 结构化输出
+type zoomEyeSearchOutput struct {
+	Success  bool                     `json:"success"`
+	Code     int                      `json:"code"`
+	Message  string                   `json:"message"`
+	Total    int                      `json:"total"`
+	Query    string                   `json:"query"`
+	Count    int                      `json:"count"`
+	Warnings []string                 `json:"warnings,omitempty"`
+	Data     []map[string]interface{} `json:"data"`
+	Cache    *cache.Result            `json:"cache"`
+	Budget   *budget.Report           `json:"budget,omitempty"`
+	Keys     *keypool.Result          `json:"keys"`
+}
 
-	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+var zoomEyeSearchOutputSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"success": map[string]interface{}{"type": "boolean"},
+		"code":    map[string]interface{}{"type": "integer"},
+		"message": map[string]interface{}{"type": "string"},
+		"total":   map[string]interface{}{"type": "integer", "description": "查询结果总数"},
+		"query":   map[string]interface{}{"type": "string"},
+		"count":   map[string]interface{}{"type": "integer", "description": "本页返回的结果数量"},
+		"warnings": map[string]interface{}{
+			"type":        "array",
+			"description": "查询语句中值格式可能不正确的条件等提示",
+			"items":       map[string]interface{}{"type": "string"},
+		},
+		"data": map[string]interface{}{
+			"type":        "array",
+			"description": "资产列表，每项包含 fields 参数请求的字段",
+			"items":       map[string]interface{}{"type": "object"},
+		},
+		"cache":  cache.ResultSchema,
+		"budget": budget.ResultSchema,
+		"keys":   keypool.ResultSchema,
+	},
+	"required": []string{"success", "total", "count", "data", "cache"},
+}
+
+func handleZoomEyeUserInfo(ctx context.Context, client *src.ZoomEyeClient) (mcp.CallToolResult, error) {
+	ctx, keyUsage := keypool.Track(ctx)
+	result, err := client.GetUserInfo(ctx)
+	if err != nil {
+		return mcp.CallToolResult{}, err
+	}
 
-	return CallToolResult{
-		Content: []map[string]interface{}{
-			{
-				"type": "text",
-				"text": string(responseJSON),
+	output := zoomEyeUserInfoOutput{
+		Success: true,
+		Code:    result.Code,
+		Message: result.Message,
+		Data: zoomEyeUserData{
+			Username:  result.Data.Username,
+			Email:     result.Data.Email,
+			Phone:     result.Data.Phone,
+			CreatedAt: result.Data.CreatedAt,
+			Subscription: zoomEyeSubscription{
+				Plan:          result.Data.Subscription.Plan,
+				EndDate:       result.Data.Subscription.EndDate,
+				Points:        result.Data.Subscription.Points,
+				ZoomEyePoints: result.Data.Subscription.ZoomEyePoints,
 			},
 		},
-	}, nil
+		Keys: keyUsage.Result(),
+	}
+	if client.Keys.Len() > 1 {
+		output.KeyPool = client.Keys.Status()
+	}
+	return mcp.StructuredResult(output), nil
 }
 
-func handleZoomEyeSearch(client *src.ZoomEyeClient, args map[string]interface{}) (CallToolResult, error) {
+func handleZoomEyeSearch(ctx context.Context, client *src.ZoomEyeClient, args map[string]interface{}) (mcp.CallToolResult, error) {
 	query, ok := args["query"].(string)
 	if !ok || query == "" {
-		return CallToolResult{}, fmt.Errorf("query参数是必需的")
+		return mcp.CallToolResult{}, fmt.Errorf("query参数是必需的")
+	}
+
+	queryWarnings, err := dork.ZoomEye.Check(query)
+	if err != nil {
+		return mcp.CallToolResult{}, err
 	}
 
 	// 对查询语句进行 Base64 编码
@@ -320,30 +364,32 @@ func handleZoomEyeSearch(client *src.ZoomEyeClient, args map[string]interface{})
 		params.IgnoreCache = ignoreCache
 	}
 
-	result, err := client.Search(params)
+	ctx, cacheStatus, err := cache.FromArgs(ctx, args)
 	if err != nil {
-		return CallToolResult{}, err
+		return mcp.CallToolResult{}, err
 	}
 
-	response := map[string]interface{}{
-		"success": true,
-		"code":    result.Code,
-		"message": result.Message,
-		"total":   result.Total,
-		"query":   result.Query,
-		"count":   len(result.Data),
-		"data":    result.Data,
+	ctx, keyUsage := keypool.Track(ctx)
+	result, err := client.Search(ctx, params)
+	if err != nil {
+		return mcp.CallToolResult{}, err
 	}
 
-	responseJSON, _ := json.MarshalIndent(response, "", "  ")
+	if result.Data == nil {
+		result.Data = []map[string]interface{}{}
+	}
 
-	return CallToolResult{
-		Content: []map[string]interface{}{
-			{
-				"type": "text",
-				"text": string(responseJSON),
-			},
-		},
-	}, nil
+	return mcp.StructuredResult(zoomEyeSearchOutput{
+		Success:  true,
+		Code:     result.Code,
+		Message:  result.Message,
+		Total:    result.Total,
+		Query:    result.Query,
+		Count:    len(result.Data),
+		Warnings: queryWarnings,
+		Data:     result.Data,
+		Cache:    cacheStatus.Result(),
+		Budget:   client.Budget.Report(),
+		Keys:     keyUsage.Result(),
+	}), nil
 }
-
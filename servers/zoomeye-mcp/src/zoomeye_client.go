@@ -1,20 +1,60 @@
 package src
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
+
+	"securitymcp-hub/pkg/budget"
+	"securitymcp-hub/pkg/cache"
+	"securitymcp-hub/pkg/dork"
+	"securitymcp-hub/pkg/httpx"
+	"securitymcp-hub/pkg/keypool"
+	"securitymcp-hub/pkg/redact"
 )
 
 // ZoomEye API 客户端
 type ZoomEyeClient struct {
-	APIKey  string
-	BaseURL string
-	Client  *http.Client
+	Keys      *keypool.Pool // API Key 池
+	BaseURL   string
+	UserAgent string
+	Client    *http.Client
+
+	// 搜索的响应缓存，nil 表示不缓存
+	Cache *cache.Cache
+
+	// 搜索的用量预算，nil 表示不限制
+	Budget *budget.Guard
+}
+
+// 客户端配置
+type ClientConfig struct {
+	BaseURL   string
+	Timeout   time.Duration
+	Proxy     string
+	UserAgent string
+
+	MaxRetries int // 网络错误、429 和 502/503/504 响应的最大重试次数
+	RateLimit  int // 每个 API Key 每分钟最多请求数，0 表示不限速
+	RateBurst  int // 限速时允许的突发请求数
+
+	CacheBackend string        // 响应缓存后端：memory、disk 或 none
+	CacheDir     string        // disk 后端的缓存目录，为空时使用用户缓存目录
+	CacheTTL     time.Duration // 缓存有效期，0 表示不缓存
+
+	ProcessBudget budget.Limits // 本进程的搜索用量上限
+	DailyBudget   budget.Limits // 每天的搜索用量上限
+	BudgetFile    string        // 每日用量的保存文件，为空时不保存
+
+	KeyStrategy keypool.Strategy // 多个 API Key 时的选择策略
+	KeyCooldown time.Duration    // 认证失败或额度不足的 key 暂停使用的时长
 }
 
 // 用户信息响应
@@ -55,34 +95,121 @@ type SearchResponse struct {
 	Data    []map[string]interface{} `json:"data"`
 }
 
-// 创建新的 ZoomEye 客户端
+// 创建新的 ZoomEye 客户端，使用默认配置
 func NewZoomEyeClient(apiKey string) *ZoomEyeClient {
 	return &ZoomEyeClient{
-		APIKey:  apiKey,
-		BaseURL: "https://api.zoomeye.org",
+		Keys:      keypool.Single(keypool.Key{Secret: apiKey}),
+		BaseURL:   "https://api.zoomeye.org",
+		UserAgent: "zoomeye-mcp/1.0",
 		Client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 	}
 }
 
+// 按配置创建 ZoomEye 客户端，用于私有部署或测试环境
+func NewZoomEyeClientWithConfig(apiKey string, cfg ClientConfig) (*ZoomEyeClient, error) {
+	return NewZoomEyeClientWithKeys([]keypool.Key{{Secret: apiKey}}, cfg)
+}
+
+// 按配置创建使用多个 API Key 的 ZoomEye 客户端
+func NewZoomEyeClientWithKeys(keys []keypool.Key, cfg ClientConfig) (*ZoomEyeClient, error) {
+	retry := httpx.DefaultRetryPolicy
+	retry.MaxRetries = cfg.MaxRetries
+	httpClient, err := httpx.NewClient(httpx.Options{
+		Timeout:   cfg.Timeout,
+		Proxy:     cfg.Proxy,
+		Retry:     retry,
+		RateLimit: float64(cfg.RateLimit) / 60,
+		RateBurst: cfg.RateBurst,
+		RateKey:   func(r *http.Request) string { return r.Header.Get("API-KEY") },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	responseCache, err := cache.Open(cfg.CacheBackend, cfg.CacheDir, "zoomeye", cfg.CacheTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	guard, err := budget.Open(cfg.ProcessBudget, cfg.DailyBudget, cfg.BudgetFile)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &ZoomEyeClient{
+		BaseURL:   strings.TrimRight(cfg.BaseURL, "/"),
+		UserAgent: cfg.UserAgent,
+		Client:    httpClient,
+		Cache:     responseCache,
+		Budget:    guard,
+	}
+	client.Keys, err = keypool.New(keys, keypool.Options{
+		Strategy: cfg.KeyStrategy,
+		Cooldown: cfg.KeyCooldown,
+		Quota:    client.keyQuota,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
 // 对查询语句进行 Base64 编码
 func (c *ZoomEyeClient) EncodeQuery(query string) string {
 	return base64.StdEncoding.EncodeToString([]byte(query))
 }
 
-// 获取用户信息
-func (c *ZoomEyeClient) GetUserInfo() (*UserInfoResponse, error) {
-	apiURL := fmt.Sprintf("%s/v2/userinfo", c.BaseURL)
+// ZoomEye 认证和额度相关的错误码，对应的 key 会被暂停使用
+var errorCodeKinds = map[int]httpx.ErrorKind{
+	60001: httpx.KindAuth,  // API Key 无效
+	60002: httpx.KindAuth,  // 账号被禁用或没有权限
+	60003: httpx.KindQuota, // 积分不足
+	60004: httpx.KindQuota, // 已达到订阅的查询上限
+}
 
-	req, err := http.NewRequest("POST", apiURL, nil)
+// ZoomEye 在 200 响应中报告的错误，按错误码判断类型，未知的错误码根据错误信息判断
+func apiError(code int, message string) error {
+	kind, ok := errorCodeKinds[code]
+	if !ok {
+		kind = httpx.ClassifyMessage(message)
+	}
+	return httpx.NewAPIError(kind, fmt.Sprintf("ZoomEye API错误: %s (code: %d)", message, code))
+}
+
+// 使用 key 池中的 API Key 发送 POST 请求并返回响应体，认证失败或额度不足时换用下一个 key。
+// 返回的错误信息中不包含凭证
+func (c *ZoomEyeClient) post(ctx context.Context, apiURL string, payload interface{}) ([]byte, error) {
+	var body []byte
+	err := c.Keys.Do(ctx, func(key keypool.Key) error {
+		var err error
+		body, err = c.postWithKey(ctx, key, apiURL, payload)
+		return err
+	})
+	return body, redact.Error(err)
+}
+
+// 使用指定的 API Key 发送 POST 请求并返回响应体，payload 为 nil 时不发送请求体，
+// 非 200 状态码和 code 不为 60000 的响应视为错误
+func (c *ZoomEyeClient) postWithKey(ctx context.Context, key keypool.Key, apiURL string, payload interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if payload != nil {
+		jsonBody, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("构建请求体失败: %w", err)
+		}
+		reqBody = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
-	req.Header.Set("API-KEY", c.APIKey)
+	req.Header.Set("API-KEY", key.Secret)
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "zoomeye-mcp/1.0")
+	req.Header.Set("User-Agent", c.UserAgent)
 
 	resp, err := c.Client.Do(req)
 	if err != nil {
@@ -96,7 +223,52 @@ func (c *ZoomEyeClient) GetUserInfo() (*UserInfoResponse, error) {
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return nil, httpx.NewStatusError(resp, fmt.Sprintf("API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body)))
+	}
+
+	var status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &status); err == nil && status.Code != 60000 {
+		return nil, apiError(status.Code, status.Message)
+	}
+
+	return body, nil
+}
+
+// 查询 API Key 的剩余积分（普通积分与权益积分之和），用于按剩余额度选择 key
+func (c *ZoomEyeClient) keyQuota(ctx context.Context, key keypool.Key) (int, error) {
+	body, err := c.postWithKey(ctx, key, fmt.Sprintf("%s/v2/userinfo", c.BaseURL), nil)
+	if err != nil {
+		return 0, err
+	}
+	var info UserInfoResponse
+	if err := json.Unmarshal(body, &info); err != nil {
+		return 0, fmt.Errorf("解析响应失败: %w", err)
+	}
+	points, _ := strconv.Atoi(info.Data.Subscription.Points)
+	zoomEyePoints, _ := strconv.Atoi(info.Data.Subscription.ZoomEyePoints)
+	return points + zoomEyePoints, nil
+}
+
+// 规范化 Base64 编码的查询语句用作缓存键，使只有空白或引号不同的查询共用缓存
+func normalizeQuery(qbase64 string) string {
+	query, err := base64.StdEncoding.DecodeString(qbase64)
+	if err != nil {
+		return qbase64
+	}
+	if expr, err := dork.ZoomEye.Parse(string(query)); err == nil {
+		return expr.String()
+	}
+	return strings.TrimSpace(string(query))
+}
+
+// 获取用户信息
+func (c *ZoomEyeClient) GetUserInfo(ctx context.Context) (*UserInfoResponse, error) {
+	body, err := c.post(ctx, fmt.Sprintf("%s/v2/userinfo", c.BaseURL), nil)
+	if err != nil {
+		return nil, err
 	}
 
 	var userInfoResp UserInfoResponse
@@ -105,14 +277,14 @@ func (c *ZoomEyeClient) GetUserInfo() (*UserInfoResponse, error) {
 	}
 
 	if userInfoResp.Code != 60000 {
-		return nil, fmt.Errorf("ZoomEye API错误: %s (code: %d)", userInfoResp.Message, userInfoResp.Code)
+		return nil, apiError(userInfoResp.Code, userInfoResp.Message)
 	}
 
 	return &userInfoResp, nil
 }
 
 // 执行资产搜索
-func (c *ZoomEyeClient) Search(params SearchParams) (*SearchResponse, error) {
+func (c *ZoomEyeClient) Search(ctx context.Context, params SearchParams) (*SearchResponse, error) {
 	// 参数验证和默认值
 	if params.Page < 1 {
 		params.Page = 1
@@ -154,33 +326,41 @@ func (c *ZoomEyeClient) Search(params SearchParams) (*SearchResponse, error) {
 		requestBody["ignore_cache"] = true
 	}
 
-	jsonBody, err := json.Marshal(requestBody)
-	if err != nil {
-		return nil, fmt.Errorf("构建请求体失败: %w", err)
-	}
+	// 只缓存成功的响应，命中缓存时不占用预算。
+	// ZoomEye 不返回消耗的积分，按每条结果 1 积分估算
+	fetch := func() ([]byte, error) {
+		reservation, err := c.Budget.Reserve(budget.Usage{Calls: 1, Results: params.PageSize, Points: params.PageSize})
+		if err != nil {
+			return nil, err
+		}
+		used := budget.Usage{Calls: 1}
+		defer func() { reservation.Settle(used) }()
 
-	req, err := http.NewRequest("POST", apiURL, strings.NewReader(string(jsonBody)))
-	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %w", err)
+		// post 会把 code 不为 60000 的响应转换为 error，错误的响应不会被缓存
+		body, err := c.post(ctx, apiURL, requestBody)
+		if err != nil {
+			return nil, err
+		}
+		var page SearchResponse
+		if err := json.Unmarshal(body, &page); err == nil {
+			used.Results = len(page.Data)
+			used.Points = len(page.Data)
+		}
+		return body, nil
 	}
 
-	req.Header.Set("API-KEY", c.APIKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "zoomeye-mcp/1.0")
-
-	resp, err := c.Client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("请求失败: %w", err)
+	// ignore_cache 要求 ZoomEye 返回最新数据，结果同样不读写本地缓存
+	var body []byte
+	var err error
+	if params.IgnoreCache {
+		body, err = c.Cache.Bypass(ctx, fetch)
+	} else {
+		key := cache.Key("zoomeye", "search", normalizeQuery(params.QBase64), params.Page, params.PageSize,
+			params.Fields, params.SubType, params.Facets)
+		body, err = c.Cache.Fetch(ctx, key, fetch)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("读取响应失败: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return nil, err
 	}
 
 	var searchResp SearchResponse
@@ -189,7 +369,7 @@ func (c *ZoomEyeClient) Search(params SearchParams) (*SearchResponse, error) {
 	}
 
 	if searchResp.Code != 60000 {
-		return nil, fmt.Errorf("ZoomEye API错误: %s (code: %d)", searchResp.Message, searchResp.Code)
+		return nil, apiError(searchResp.Code, searchResp.Message)
 	}
 
 	return &searchResp, nil
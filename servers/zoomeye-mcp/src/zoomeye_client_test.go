@@ -0,0 +1,114 @@
+package src
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"securitymcp-hub/pkg/keypool"
+)
+
+func TestNoCredentialsInErrors(t *testing.T) {
+	const secret = "zoomeye-secret-key-0123456789"
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"响应中回显请求头", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, "bad request, API-KEY: %s", r.Header.Get("API-KEY"))
+		}},
+		{"错误信息中包含 key", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"code":60001,"message":"invalid api key %s"}`, r.Header.Get("API-KEY"))
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewServer(tc.handler)
+			defer ts.Close()
+
+			client, err := NewZoomEyeClientWithConfig(secret, ClientConfig{BaseURL: ts.URL, Timeout: time.Second})
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = client.GetUserInfo(context.Background())
+			if err == nil {
+				t.Fatal("期望返回错误")
+			}
+			if strings.Contains(err.Error(), secret) {
+				t.Fatalf("错误信息中包含凭证: %v", err)
+			}
+		})
+	}
+}
+
+func TestIgnoreCacheNotStored(t *testing.T) {
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprint(w, `{"code":60000,"message":"success","total":1,"data":[{"ip":"1.2.3.4"}]}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewZoomEyeClientWithConfig("key", ClientConfig{BaseURL: ts.URL, CacheBackend: "memory", CacheTTL: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := SearchParams{QBase64: client.EncodeQuery(`app="nginx"`), PageSize: 10}
+
+	// ignore_cache 的结果不写入缓存，之后的普通查询仍会请求 API
+	params.IgnoreCache = true
+	if _, err := client.Search(context.Background(), params); err != nil {
+		t.Fatal(err)
+	}
+	params.IgnoreCache = false
+	for i := 0; i < 2; i++ {
+		if _, err := client.Search(context.Background(), params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("请求 %d 次，期望 2 次", calls)
+	}
+}
+
+func TestKeyFailover(t *testing.T) {
+	var usedKeys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("API-KEY")
+		usedKeys = append(usedKeys, key)
+		if key == "drained" {
+			// 错误信息中没有关键词，按错误码判断为额度不足
+			fmt.Fprint(w, `{"code":60003,"message":"request denied"}`)
+			return
+		}
+		fmt.Fprint(w, `{"code":60000,"message":"success","total":1,"data":[{"ip":"1.2.3.4"}]}`)
+	}))
+	defer ts.Close()
+
+	client, err := NewZoomEyeClientWithKeys([]keypool.Key{
+		{Label: "main", Secret: "drained"},
+		{Label: "backup", Secret: "ok"},
+	}, ClientConfig{BaseURL: ts.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		ctx, usage := keypool.Track(context.Background())
+		if _, err := client.Search(ctx, SearchParams{QBase64: client.EncodeQuery(`app="nginx"`), Page: i + 1}); err != nil {
+			t.Fatal(err)
+		}
+		if result := usage.Result(); len(result.ServedBy) != 1 || result.ServedBy[0] != "backup" {
+			t.Fatalf("第 %d 次查询使用的 key 为 %+v", i+1, result)
+		}
+	}
+	// 额度不足的 key 被暂停后不再使用
+	if fmt.Sprint(usedKeys) != "[drained ok ok]" {
+		t.Fatalf("请求使用的 key 依次为 %v", usedKeys)
+	}
+}